@@ -0,0 +1,137 @@
+package cognito
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// CognitoClaims holds the claims of a Cognito id or access token with their
+// Go types. Claims missing from the token are left at their zero value.
+type CognitoClaims struct {
+	Sub           string
+	Username      string
+	Email         string
+	EmailVerified bool
+	TokenUse      string
+	Groups        []string
+	Scopes        []string
+	ClientId      string
+	Issuer        string
+	Audience      []string
+	AuthTime      time.Time
+	Exp           time.Time
+	Iat           time.Time
+}
+
+// ParseClaims maps the claims of a verified token into CognitoClaims. The
+// username is read from username when present, as in access tokens, and from
+// cognito:username otherwise, the groups from cognito:groups.
+func ParseClaims(token *jwt.Token) (*CognitoClaims, error) {
+	if token == nil {
+		return nil, errors.New("token is nil")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("unsupported claims type %T", token.Claims)
+	}
+
+	parsed := &CognitoClaims{
+		Audience: tokenAudiences(claims),
+	}
+	if scopes := tokenScopes(token); len(scopes) > 0 {
+		parsed.Scopes = scopes
+	}
+	if tokenUse, _ := claims["token_use"].(string); tokenUse == "access" {
+		// tokenAudiences reports client_id for access tokens, which have no aud
+		parsed.Audience = nil
+	}
+	strs := []struct {
+		name string
+		dst  *string
+	}{
+		{"sub", &parsed.Sub},
+		{"email", &parsed.Email},
+		{"token_use", &parsed.TokenUse},
+		{"client_id", &parsed.ClientId},
+		{"iss", &parsed.Issuer},
+		{usernameClaim(claims), &parsed.Username},
+	}
+	for _, s := range strs {
+		if err := stringClaim(claims, s.name, s.dst); err != nil {
+			return nil, err
+		}
+	}
+
+	switch v := claims["email_verified"].(type) {
+	case nil:
+	case bool:
+		parsed.EmailVerified = v
+	case string:
+		// some Cognito tokens carry booleans as strings
+		parsed.EmailVerified = strings.EqualFold(v, "true")
+	default:
+		return nil, fmt.Errorf("claim email_verified must be a boolean, got %T", v)
+	}
+
+	if groups, ok := claims["cognito:groups"]; ok && groups != nil {
+		list, ok := groups.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("claim cognito:groups must be an array, got %T", groups)
+		}
+		for _, g := range list {
+			group, ok := g.(string)
+			if !ok {
+				return nil, fmt.Errorf("claim cognito:groups must hold strings, got %T", g)
+			}
+			parsed.Groups = append(parsed.Groups, group)
+		}
+	}
+
+	times := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"auth_time", &parsed.AuthTime},
+		{"exp", &parsed.Exp},
+		{"iat", &parsed.Iat},
+	}
+	for _, t := range times {
+		if v, ok := claims[t.name]; !ok || v == nil {
+			continue
+		}
+		sec, ok := claimTime(claims, t.name)
+		if !ok {
+			return nil, fmt.Errorf("claim %s must be a number", t.name)
+		}
+		*t.dst = time.Unix(sec, 0)
+	}
+	return parsed, nil
+}
+
+// usernameClaim names the claim holding the username: username, set in access
+// tokens, when present and cognito:username, set in id tokens, otherwise.
+// ParseClaims and the middlewares both use it so they agree on the username.
+func usernameClaim(claims jwt.MapClaims) string {
+	if v, ok := claims["username"]; ok && v != nil {
+		return "username"
+	}
+	return "cognito:username"
+}
+
+// stringClaim copies the named claim into dst when present
+func stringClaim(claims jwt.MapClaims, name string, dst *string) error {
+	v, ok := claims[name]
+	if !ok || v == nil {
+		return nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("claim %s must be a string, got %T", name, v)
+	}
+	*dst = s
+	return nil
+}
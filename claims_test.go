@@ -0,0 +1,132 @@
+package cognito
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClaims(t *testing.T) {
+	issuedAt := time.Unix(1500009400, 0)
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		want    *CognitoClaims
+		wantErr error
+	}{
+		{
+			name: "Id token",
+			claims: jwt.MapClaims{
+				"sub":              "aaaaaaaa-bbbb-cccc-dddd-example",
+				"aud":              testClientId,
+				"email_verified":   true,
+				"token_use":        "id",
+				"auth_time":        float64(issuedAt.Unix()),
+				"iss":              testIss,
+				"cognito:username": "anaya",
+				"cognito:groups":   []interface{}{"admin", "editors"},
+				"exp":              float64(issuedAt.Add(time.Hour).Unix()),
+				"iat":              float64(issuedAt.Unix()),
+				"email":            "anaya@example.com",
+			},
+			want: &CognitoClaims{
+				Sub:           "aaaaaaaa-bbbb-cccc-dddd-example",
+				Username:      "anaya",
+				Email:         "anaya@example.com",
+				EmailVerified: true,
+				TokenUse:      "id",
+				Groups:        []string{"admin", "editors"},
+				Issuer:        testIss,
+				Audience:      []string{testClientId},
+				AuthTime:      issuedAt,
+				Exp:           issuedAt.Add(time.Hour),
+				Iat:           issuedAt,
+			},
+		},
+		{
+			name: "Access token",
+			claims: jwt.MapClaims{
+				"sub":       "aaaaaaaa-bbbb-cccc-dddd-example",
+				"token_use": "access",
+				"scope":     "myapi/read myapi/write",
+				"client_id": testClientId,
+				"username":  "anaya",
+				"iss":       testIss,
+				"exp":       float64(issuedAt.Add(time.Hour).Unix()),
+			},
+			want: &CognitoClaims{
+				Sub:      "aaaaaaaa-bbbb-cccc-dddd-example",
+				Username: "anaya",
+				TokenUse: "access",
+				Scopes:   []string{"myapi/read", "myapi/write"},
+				ClientId: testClientId,
+				Issuer:   testIss,
+				Exp:      issuedAt.Add(time.Hour),
+			},
+		},
+		{
+			name:   "Both username claims",
+			claims: jwt.MapClaims{"username": "anaya", "cognito:username": "other"},
+			want:   &CognitoClaims{Username: "anaya"},
+		},
+		{
+			name:   "Missing optional claims",
+			claims: jwt.MapClaims{"sub": "aaaaaaaa-bbbb-cccc-dddd-example"},
+			want: &CognitoClaims{
+				Sub: "aaaaaaaa-bbbb-cccc-dddd-example",
+			},
+		},
+		{
+			name:   "String email_verified",
+			claims: jwt.MapClaims{"email_verified": "true"},
+			want:   &CognitoClaims{EmailVerified: true},
+		},
+		{
+			name:    "Wrong type",
+			claims:  jwt.MapClaims{"email": 42},
+			wantErr: errors.New("claim email must be a string, got int"),
+		},
+		{
+			name:    "Wrong groups type",
+			claims:  jwt.MapClaims{"cognito:groups": "admin"},
+			wantErr: errors.New("claim cognito:groups must be an array, got string"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseClaims(&jwt.Token{Claims: tt.claims})
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			// the middlewares report the same username
+			if got.Username != "" {
+				assert.Equal(t, got.Username, tokenUsername(&jwt.Token{Claims: tt.claims}))
+			}
+		})
+	}
+}
+
+func TestParseClaims_VerifiedToken(t *testing.T) {
+	token, err := newTestCognito(t).VerifyToken(signTestToken(t, testClaims(jwt.MapClaims{
+		"cognito:groups": []string{"admin"},
+	})))
+	require.NoError(t, err)
+
+	got, err := ParseClaims(token)
+	require.NoError(t, err)
+	assert.Equal(t, "anaya", got.Username)
+	assert.Equal(t, "anaya@example.com", got.Email)
+	assert.Equal(t, []string{"admin"}, got.Groups)
+	assert.True(t, got.EmailVerified)
+	assert.False(t, got.Exp.IsZero())
+
+	_, err = ParseClaims(nil)
+	assert.Error(t, err)
+}
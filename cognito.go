@@ -1,13 +1,29 @@
 package cognito
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"net/http"
+	"net/url"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -15,9 +31,59 @@ import (
 )
 
 var (
-	ErrInvalidParam = errors.New("invalid param")
+	ErrInvalidParam          = errors.New("invalid param")
+	ErrTokenLifetimeTooLong  = errors.New("token lifetime is too long")
+	ErrInvalidSub            = errors.New("sub is invalid")
+	ErrKIDNotAllowed         = errors.New("kid is not allowed")
+	ErrTokenRevoked          = errors.New("token is revoked")
+	ErrMissingClaim          = errors.New("missing claim")
+	ErrWeakKey               = errors.New("key is too small")
+	ErrNonceMismatch         = errors.New("nonce does not match")
+	ErrAudClientIDMismatch   = errors.New("aud and client_id do not match")
+	ErrMissingTokenUse       = errors.New("missing token_use")
+	ErrDuplicateClaim        = errors.New("duplicate claim")
+	ErrMalformedToken        = errors.New("malformed token")
+	ErrIssuerAudienceBinding = errors.New("audience is not bound to issuer")
+	ErrInvalidAudience       = errors.New("audience is invalid")
+	ErrInvalidIssuer         = errors.New("iss is invalid")
+	ErrTokenExpired          = errors.New("token expired")
+	ErrTokenNotValidYet      = errors.New("token is not valid yet")
+	ErrTokenUsedBeforeIssued = errors.New("token used before issued")
+	ErrInvalidKid            = errors.New("invalid kid")
+	ErrUnknownIssuer         = errors.New("unknown issuer")
+	ErrKeyAlgMismatch        = errors.New("key alg does not match token alg")
+	ErrInvalidSignature      = errors.New("signature is invalid")
+	ErrInvalidSigningMethod  = errors.New("invalid signing method")
+	ErrNoKeys                = errors.New("no public keys loaded")
+	ErrStaleKeys             = errors.New("public keys are stale")
+	ErrNotIDToken            = errors.New("token is not an id token")
 )
 
+// Version of this package, sent in the default User-Agent
+const Version = "0.1.0"
+
+const (
+	// timeout of the default client used to fetch the JWKS
+	defaultHTTPTimeout = 10 * time.Second
+
+	defaultUserAgent = "cognito-go/" + Version
+
+	// minimum time between JWKS refreshes triggered by unknown kids
+	jwksRefreshInterval = time.Minute
+
+	// how long fetched keys stay fresh when the response has no usable Cache-Control or Expires
+	defaultJWKSMaxAge = time.Hour
+
+	// StartKeyRefresh interval used when the given one is not positive
+	defaultKeyRefreshInterval = time.Minute
+)
+
+// signing algorithms accepted when AllowedAlgs is empty
+var defaultAllowedAlgs = []string{"RS256"}
+
+// token_use values accepted when AllowedTokenUse is empty
+var defaultAllowedTokenUse = []string{"id", "access"}
+
 //go:generate mockgen -source=cognito.go -package=cognito -destination=mocks/cognito.go
 type Client interface {
 	VerifyToken(tokenStr string) (*jwt.Token, error)
@@ -28,117 +94,1527 @@ type Cognito struct {
 	// AWS App Client ID
 	ClientId string
 
+	// Additional app client ids whose tokens are accepted
+	ClientIds []string
+
 	// AWS Cognito Issuer
 	Iss string
 
-	// Map of JWKs from AWS Cognito
+	// DNS suffix NewCognitoClient builds the issuer with, defaults to
+	// amazonaws.com.cn for cn- regions and amazonaws.com otherwise
+	EndpointSuffix string
+
+	// Map of JWKs from AWS Cognito. Set it before the client is used, after
+	// that it is replaced by JWKS refreshes and should be read with GetKeys.
 	PublicKeys PublicKeys
+
+	// Maximum allowed lifetime (exp - iat) of a token, zero means no limit
+	MaxTokenLifetime time.Duration
+
+	// Require the sub claim to be a UUID
+	ValidateSubUUID bool
+
+	// Headers added to auth failure responses, e.g. Access-Control-Allow-Origin
+	ErrorCORSHeaders map[string]string
+
+	// Clock used for time based checks, defaults to time.Now
+	TimeFunc func() time.Time
+
+	// Clock skew tolerated when checking exp, nbf and iat
+	Leeway time.Duration
+
+	// Kids that may be used for verification, empty allows every loaded key
+	AllowedKIDs []string
+
+	// Checks the origin_jti claim against revoked sessions
+	OriginJTIRevocationChecker RevocationChecker
+
+	// Claims that must be present in every token
+	RequiredClaims []string
+
+	// Treat required claims holding an empty string as missing
+	RejectEmptyClaims bool
+
+	// Called on the JWKS request before it is sent, e.g. to add SigV4 headers
+	RequestSigner func(*http.Request) error
+
+	// Minimum RSA modulus size in bits for keys loaded from the JWKS, smaller keys are skipped
+	MinRSABits int
+
+	// Prefix under which Authorize sets every verified claim in the gin context, empty disables it
+	ClaimsContextPrefix string
+
+	// Max age after iat for tokens without exp, zero requires exp
+	FallbackExpiry time.Duration
+
+	// Run every claim check and return all failures instead of the first one
+	CollectAllErrors bool
+
+	// Client used to fetch the JWKS, defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+
+	// Creates spans around token verification and JWKS fetches
+	Tracer Tracer
+
+	// Require aud and client_id to agree when a token carries both
+	AudClientIDConsistency bool
+
+	// Also accept https issuers on any host whose path is exactly this, e.g. "/us-east-1_example"
+	IssuerSuffix string
+
+	// Accept signatures encoded with standard base64 instead of base64url
+	LenientBase64 bool
+
+	// Reject tokens without a token_use claim
+	RequireTokenUse bool
+
+	// Reject tokens whose payload repeats a claim key
+	RejectDuplicateClaims bool
+
+	// User-Agent sent when fetching the JWKS, defaults to cognito-go/<version>
+	UserAgent string
+
+	// Accepted token_use values, defaults to "id" and "access"
+	AllowedTokenUse []string
+
+	// JWKS endpoint, refetched when a token is signed with an unknown kid
+	JWKSURL string
+
+	// Called when a refresh started by StartKeyRefresh fails
+	OnKeyRefreshError func(error)
+
+	// Require iss, sub, aud, exp and iat with their OIDC types
+	StrictOIDC bool
+
+	// Require token_use, iss, exp, iat and aud, or client_id for access
+	// tokens, instead of skipping the checks of missing claims
+	Strict bool
+
+	// Total attempts at fetching the JWKS when it fails with a connection
+	// error or a 5xx response, zero or one disables retries
+	RetryAttempts int
+
+	// Delay before the first retry, doubled after every attempt
+	RetryBaseDelay time.Duration
+
+	// JWKS document used instead of fetching the keys, disables refreshes
+	PinnedJWKS []byte
+
+	// Audiences each issuer may issue tokens for, checked after the standard
+	// aud and iss checks. Empty disables the check.
+	IssuerAudiences map[string][]string
+
+	// Reads the token from requests in the middlewares, defaults to FromAuthHeader
+	TokenExtractor func(*http.Request) (string, error)
+
+	// Context keys set by Authorize, EchoMiddleware and FiberMiddleware
+	ContextKeys ContextKeys
+
+	// Renders gin auth failures instead of the default 403 JSON body, the
+	// request is aborted after it returns
+	OnError func(c *gin.Context, err error)
+
+	// Receives diagnostics, nothing is logged when nil
+	Logger Logger
+
+	// Accepted signing algorithms, defaults to RS256. Only RS256/384/512 and
+	// PS256/384/512 can be verified with the RSA keys of the JWKS.
+	AllowedAlgs []string
+
+	// Called at the end of every verification with one of the VerifyResult
+	// labels and the error, if any, e.g. to count outcomes
+	OnVerify func(result string, err error)
+
+	// Ready fails when the keys were last fetched longer ago than this, zero
+	// disables the check. Pinned keys never go stale.
+	MaxKeyStaleness time.Duration
+
+	// guards PublicKeys, keysExpiry and keysFetched against concurrent refreshes
+	keysMu      sync.RWMutex
+	keysExpiry  time.Time
+	keysFetched time.Time
+
+	refreshMu   sync.Mutex
+	lastRefresh time.Time
+}
+
+// RevocationChecker reports whether a token identifier has been revoked.
+type RevocationChecker interface {
+	IsRevoked(id string) (bool, error)
+}
+
+// RevocationCheckerFunc adapts a function to the RevocationChecker interface.
+type RevocationCheckerFunc func(id string) (bool, error)
+
+func (f RevocationCheckerFunc) IsRevoked(id string) (bool, error) {
+	return f(id)
 }
 
 type PublicKey struct {
-	Alg string `json:"alg"`
-	E   string `json:"e"`
-	Kid string `json:"kid"`
-	Kty string `json:"kty"`
-	N   string `json:"n"`
-	Use string `json:"use"`
-	PEM *rsa.PublicKey
+	Alg     string         `json:"alg"`
+	E       string         `json:"e"`
+	Kid     string         `json:"kid"`
+	Kty     string         `json:"kty"`
+	N       string         `json:"n"`
+	Use     string         `json:"use"`
+	X5t     string         `json:"x5t,omitempty"`
+	X5tS256 string         `json:"x5t#S256,omitempty"`
+	PEM     *rsa.PublicKey `json:"-"`
 }
 
 type PublicKeys map[string]PublicKey
 
-func NewCognitoClient(region, usePoolId, clientId string) (Client, error) {
+func NewCognitoClient(region, usePoolId, clientId string, opts ...Option) (Client, error) {
+	return NewCognitoClientWithContext(context.Background(), region, usePoolId, clientId, opts...)
+}
+
+// NewCognitoClientWithContext is like NewCognitoClient but the initial JWKS
+// fetch is cancelled when ctx is done.
+func NewCognitoClientWithContext(ctx context.Context, region, usePoolId, clientId string, opts ...Option) (Client, error) {
 	// validate region and usePoolId, make sure they are present
 	if region == "" || usePoolId == "" {
 		return nil, fmt.Errorf("invalid region or use pool id: %w", ErrInvalidParam)
 	}
 
-	iss := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, usePoolId)
-	pkUrl := fmt.Sprintf("%s/.well-known/jwks.json", iss)
-	publicKeys, err := getPublicKeys(pkUrl)
+	c := &Cognito{
+		ClientId: clientId,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	suffix := c.EndpointSuffix
+	if suffix == "" {
+		suffix = endpointSuffix(region)
+	}
+	iss := fmt.Sprintf("https://cognito-idp.%s.%s/%s", region, suffix, usePoolId)
+	if c.Iss == "" {
+		c.Iss = iss
+	}
+	if c.JWKSURL == "" {
+		c.JWKSURL = fmt.Sprintf("%s/.well-known/jwks.json", iss)
+	}
+
+	var publicKeys PublicKeys
+	var expiry time.Time
+	var err error
+	if c.PinnedJWKS != nil {
+		publicKeys, err = c.parseJWKS(bytes.NewReader(c.PinnedJWKS))
+	} else {
+		publicKeys, expiry, err = c.getPublicKeys(ctx, c.JWKSURL)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if len(publicKeys) == 0 {
+		c.logger().Errorf("cognito: JWKS from %s has no usable keys", c.JWKSURL)
+	}
+	c.setPublicKeys(publicKeys, expiry)
+	return c, nil
+}
 
-	return &Cognito{
+// endpointSuffix returns the DNS suffix of the AWS partition region belongs to
+func endpointSuffix(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return "amazonaws.com.cn"
+	}
+	return "amazonaws.com"
+}
+
+// NewCognitoClientFromJWKS builds a client from a JWKS document instead of
+// fetching the keys, for offline use or a JWKS embedded at build time. The
+// keys are never refreshed.
+func NewCognitoClientFromJWKS(iss, clientId string, jwks []byte, opts ...Option) (Client, error) {
+	c := &Cognito{
 		ClientId:   clientId,
 		Iss:        iss,
-		PublicKeys: publicKeys,
-	}, nil
+		PinnedJWKS: jwks,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	publicKeys, err := c.parseJWKS(bytes.NewReader(c.PinnedJWKS))
+	if err != nil {
+		return nil, err
+	}
+	c.setPublicKeys(publicKeys, time.Time{})
+	return c, nil
+}
+
+var (
+	regionPattern   = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-\d+$`)
+	poolIdPattern   = regexp.MustCompile(`^([a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-\d+)_[0-9a-zA-Z]+$`)
+	clientIdPattern = regexp.MustCompile(`^[\w+]+$`)
+)
+
+// ValidateConfig checks the format of the values passed to NewCognitoClient
+// without making any network call, so configuration can be validated early.
+func ValidateConfig(region, usePoolId, clientId string) error {
+	if !regionPattern.MatchString(region) {
+		return fmt.Errorf("invalid region %q: %w", region, ErrInvalidParam)
+	}
+	m := poolIdPattern.FindStringSubmatch(usePoolId)
+	if m == nil {
+		return fmt.Errorf("invalid use pool id %q: %w", usePoolId, ErrInvalidParam)
+	}
+	if m[1] != region {
+		return fmt.Errorf("use pool id %q is not in region %s: %w", usePoolId, region, ErrInvalidParam)
+	}
+	if len(clientId) > 128 || !clientIdPattern.MatchString(clientId) {
+		return fmt.Errorf("invalid client id %q: %w", clientId, ErrInvalidParam)
+	}
+	return nil
+}
+
+// NewCognitoClientWithTransport is like NewCognitoClient but fetches the JWKS
+// through rt, which lets tests serve a canned JWKS without a real server.
+func NewCognitoClientWithTransport(region, usePoolId, clientId string, rt http.RoundTripper, opts ...Option) (Client, error) {
+	withTransport := WithHTTPClient(&http.Client{
+		Timeout:   defaultHTTPTimeout,
+		Transport: rt,
+	})
+	return NewCognitoClient(region, usePoolId, clientId, append([]Option{withTransport}, opts...)...)
 }
 
+// VerifyToken parses tokenStr, verifies its signature against the loaded keys
+// and validates its claims.
+//
+// When the token can't be parsed or its signature can't be verified (unknown
+// kid, bad signature, wrong algorithm) the returned token is nil. When the
+// signature is valid but a claim check fails (exp, iat, nbf, aud, iss, ...)
+// the parsed token is returned together with the error, so callers can log
+// the offending claims. The token must not be trusted in that case.
+//
+// When a token has several problems the first one is reported, in this order:
+// malformed token (ErrMalformedToken), key lookup (ErrInvalidKid, ...), bad
+// signature (ErrInvalidSignature), expiry (ErrTokenExpired), iat and nbf,
+// issuer (ErrInvalidIssuer), audience (ErrInvalidAudience), then the optional
+// checks. WithRejectDuplicateClaims, WithStrictOIDC and WithStrict run before
+// the expiry check.
 func (c *Cognito) VerifyToken(tokenStr string) (*jwt.Token, error) {
-	// parse token and verify signature
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+	token, _, err := c.VerifyTokenWithKey(tokenStr)
+	return token, err
+}
+
+// VerifyTokenWithKey is like VerifyToken and also returns the key that
+// verified the signature, e.g. to log its kid. The key is returned whenever
+// the token is, and is the zero PublicKey otherwise.
+func (c *Cognito) VerifyTokenWithKey(tokenStr string) (*jwt.Token, PublicKey, error) {
+	return c.verify(context.Background(), tokenStr, nil)
+}
+
+// VerifyTokens verifies tokenStrs concurrently on a bounded pool of workers
+// and returns the tokens and errors at the same indexes as their inputs, as
+// VerifyToken would for each of them. Each worker reads the key map once.
+func (c *Cognito) VerifyTokens(tokenStrs []string) ([]*jwt.Token, []error) {
+	tokens := make([]*jwt.Token, len(tokenStrs))
+	errs := make([]error, len(tokenStrs))
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(tokenStrs) {
+		workers = len(tokenStrs)
+	}
+	next := int32(-1)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			// refreshes replace the map rather than modifying it, so it can be used after unlocking
+			c.keysMu.RLock()
+			keys := c.PublicKeys
+			c.keysMu.RUnlock()
+			for {
+				i := int(atomic.AddInt32(&next, 1))
+				if i >= len(tokenStrs) {
+					return
+				}
+				tokens[i], _, errs[i] = c.verify(context.Background(), tokenStrs[i], keys)
+			}
+		}()
+	}
+	wg.Wait()
+	return tokens, errs
+}
+
+// VerifyTokenWithContext is like VerifyToken, ctx cancels the JWKS refresh
+// started when the token is signed with an unknown kid.
+func (c *Cognito) VerifyTokenWithContext(ctx context.Context, tokenStr string) (*jwt.Token, error) {
+	token, _, err := c.verify(ctx, tokenStr, nil)
+	return token, err
+}
+
+// verify runs verifyToken, traced when a Tracer is set, and reports the outcome.
+// keys, when not nil, is a snapshot of PublicKeys searched before taking the keys lock.
+func (c *Cognito) verify(ctx context.Context, tokenStr string, keys PublicKeys) (token *jwt.Token, key PublicKey, err error) {
+	if c.Tracer != nil {
+		token, key, err = c.traceVerifyToken(ctx, tokenStr, keys)
+	} else {
+		token, key, err = c.verifyToken(ctx, tokenStr, keys)
+	}
+	if err != nil {
+		c.logger().Debugf("cognito: token rejected: %v", err)
+	}
+	if c.OnVerify != nil {
+		c.OnVerify(verifyResult(err), err)
+	}
+	return token, key, err
+}
+
+// ParseAndValidate verifies tokenStr against keys without a configured client,
+// for tools and tests that bring their own key set. The expected aud and iss
+// are set with WithClientID and WithIssuer, other options work as they do for
+// NewCognitoClient. Keys are never refetched. It runs on a Cognito built from
+// keys and opts, so it applies exactly the checks VerifyToken does.
+func ParseAndValidate(tokenStr string, keys PublicKeys, opts ...Option) (*jwt.Token, error) {
+	c := &Cognito{PublicKeys: keys}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c.VerifyToken(tokenStr)
+}
+
+// ParseUnverified decodes the header and claims of tokenStr WITHOUT verifying
+// the signature or any claim. It is meant for logging and routing, e.g.
+// picking a user pool by iss, and its result must not be trusted. Use
+// VerifyToken to validate the token.
+func ParseUnverified(tokenStr string) (*jwt.Token, jwt.MapClaims, error) {
+	if segments := strings.Count(tokenStr, ".") + 1; segments != 3 {
+		return nil, nil, fmt.Errorf("%w: expected 3 segments, found %d", ErrMalformedToken, segments)
+	}
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenStr, jwt.MapClaims{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: unexpected claims type %T", ErrMalformedToken, token.Claims)
+	}
+	return token, claims, nil
+}
+
+func (c *Cognito) verifyToken(ctx context.Context, tokenStr string, keys PublicKeys) (*jwt.Token, PublicKey, error) {
+	if c.LenientBase64 {
+		tokenStr = normalizeSignature(tokenStr)
+	}
+	if segments := strings.Count(tokenStr, ".") + 1; segments != 3 {
+		return nil, PublicKey{}, fmt.Errorf("%w: expected 3 segments, found %d", ErrMalformedToken, segments)
+	}
+
+	// parse token and verify signature, claims are validated below
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	var key PublicKey
+	token, err := parser.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 		// validate token signing method
-		if alg := token.Method.Alg(); alg != "RS256" {
-			return nil, fmt.Errorf("invalid signing method %s. signing method must be RS256", alg)
+		alg := token.Method.Alg()
+		// only RSA methods are verified with the JWKS keys, this rejects none and
+		// HMAC methods even if they are allowed, preventing algorithm confusion
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		default:
+			return nil, fmt.Errorf("%w %s. only RSA signatures are accepted", ErrInvalidSigningMethod, alg)
+		}
+		if !containsString(c.allowedAlgs(), alg) {
+			return nil, fmt.Errorf("%w %s. signing method must be %s", ErrInvalidSigningMethod, alg, strings.Join(c.allowedAlgs(), " or "))
 		}
-		return c.getCert(token)
+		var err error
+		if key, err = c.getKey(ctx, token, keys); err != nil {
+			return nil, err
+		}
+		return key.PEM, nil
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, PublicKey{}, parseError(err)
+	}
+	// claim checks rely on the default map claims
+	if _, ok := token.Claims.(jwt.MapClaims); !ok {
+		return nil, PublicKey{}, fmt.Errorf("%w: unexpected claims type %T", ErrMalformedToken, token.Claims)
 	}
 
 	// verify claims
-	// verify audience claim
-	if !token.Claims.(jwt.MapClaims).VerifyAudience(c.ClientId, false) {
-		return token, errors.New("audience is invalid")
+	var errs []error
+	for _, check := range c.claimChecks() {
+		if err := check(token); err != nil {
+			if !c.CollectAllErrors {
+				return token, key, err
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return token, key, multiError(errs)
+	}
+
+	return token, key, nil
+}
+
+// parseError maps the errors of jwt.Parser.Parse to the package sentinels.
+// Claims aren't validated by the parser, so only parse, key lookup and
+// signature failures reach it.
+func parseError(err error) error {
+	ve, ok := err.(*jwt.ValidationError)
+	if !ok {
+		return err
+	}
+	switch {
+	case ve.Errors&jwt.ValidationErrorUnverifiable != 0 && ve.Inner != nil:
+		// return key lookup errors as is so they can be matched with errors.Is
+		return ve.Inner
+	case ve.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, ve.Inner)
+	case ve.Errors&jwt.ValidationErrorMalformed != 0:
+		return fmt.Errorf("%w: %v", ErrMalformedToken, ve.Inner)
+	}
+	return err
+}
+
+// normalizeSignature re-encodes a signature segment written with the standard
+// base64 alphabet or padding into the unpadded base64url form JWTs require
+func normalizeSignature(tokenStr string) string {
+	i := strings.LastIndex(tokenStr, ".")
+	if i < 0 {
+		return tokenStr
+	}
+	sig := strings.TrimRight(tokenStr[i+1:], "=")
+	sig = strings.NewReplacer("+", "-", "/", "_").Replace(sig)
+	return tokenStr[:i+1] + sig
+}
+
+// claimChecks returns the claim validations run by VerifyToken, in the order
+// documented on VerifyToken
+func (c *Cognito) claimChecks() []func(*jwt.Token) error {
+	return []func(*jwt.Token) error{
+		c.verifyNoDuplicateClaims,
+		c.verifyStrictOIDC,
+		c.verifyStrict,
+		c.verifyExpiry,
+		c.verifyIssuedAt,
+		c.verifyNotBefore,
+		c.verifyIssuer,
+		c.verifyAudience,
+		c.verifyAudClientID,
+		c.verifyTokenUsePresent,
+		c.verifyTokenUseAllowed,
+		c.verifySub,
+		c.verifyRequiredClaims,
+		c.verifyNotRevoked,
+		c.verifyLifetime,
+		c.verifyIssuerAudienceBinding,
+	}
+}
+
+// verifyNoDuplicateClaims rejects payloads with repeated keys, which json.Unmarshal
+// silently resolves to the last value
+func (c *Cognito) verifyNoDuplicateClaims(token *jwt.Token) error {
+	if !c.RejectDuplicateClaims {
+		return nil
+	}
+	parts := strings.Split(token.Raw, ".")
+	if len(parts) != 3 {
+		return errors.New("token is malformed")
+	}
+	payload, err := jwt.DecodeSegment(parts[1])
+	if err != nil {
+		return err
+	}
+	dup, err := findDuplicateKey(json.NewDecoder(bytes.NewReader(payload)))
+	if err != nil {
+		return err
+	}
+	if dup != "" {
+		return fmt.Errorf("%w %s", ErrDuplicateClaim, dup)
+	}
+	return nil
+}
+
+// findDuplicateKey walks the next JSON value in dec and returns the first
+// object key repeated within the same object, at any depth
+func findDuplicateKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return "", nil
+	}
+	seen := make(map[string]bool)
+	for dec.More() {
+		if delim == '{' {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return "", err
+			}
+			key := keyTok.(string)
+			if seen[key] {
+				return key, nil
+			}
+			seen[key] = true
+		}
+		if dup, err := findDuplicateKey(dec); err != nil || dup != "" {
+			return dup, err
+		}
+	}
+	// consume the closing delimiter
+	_, err = dec.Token()
+	return "", err
+}
+
+// verifyAudience checks the token was issued to the app client. Access tokens
+// have no aud and carry the app client id in client_id instead.
+func (c *Cognito) verifyAudience(token *jwt.Token) error {
+	claims := token.Claims.(jwt.MapClaims)
+	if tokenUse, _ := claims["token_use"].(string); tokenUse == "access" {
+		if clientId, _ := claims["client_id"].(string); clientId == "" || !containsString(c.clientIds(), clientId) {
+			return fmt.Errorf("%w: client_id is invalid", ErrInvalidAudience)
+		}
+		return nil
+	}
+	if _, ok := claims["aud"]; !ok {
+		return nil
+	}
+	// aud may be a string or an array of strings, jwt-go only understands the former
+	for _, aud := range tokenAudiences(claims) {
+		if aud != "" && containsString(c.clientIds(), aud) {
+			return nil
+		}
+	}
+	// access tokens carry the app client id in client_id, a common source of confusion
+	if clientId, _ := claims["client_id"].(string); clientId != "" && containsString(c.clientIds(), clientId) {
+		return fmt.Errorf("%w: this looks like an access token, its client_id matches but aud does not", ErrInvalidAudience)
+	}
+	return ErrInvalidAudience
+}
+
+// clientIds returns ClientId and ClientIds
+func (c *Cognito) clientIds() []string {
+	if len(c.ClientIds) == 0 {
+		return []string{c.ClientId}
+	}
+	ids := make([]string, 0, len(c.ClientIds)+1)
+	if c.ClientId != "" {
+		ids = append(ids, c.ClientId)
+	}
+	return append(ids, c.ClientIds...)
+}
+
+func (c *Cognito) verifyIssuer(token *jwt.Token) error {
+	claims := token.Claims.(jwt.MapClaims)
+	if claims.VerifyIssuer(c.Iss, true) {
+		return nil
+	}
+	if iss, ok := claims["iss"].(string); ok && c.issuerSuffixMatches(iss) {
+		return nil
+	}
+	return ErrInvalidIssuer
+}
+
+// issuerSuffixMatches reports whether iss is an https URL whose path is exactly IssuerSuffix.
+// Only the host may differ from the configured issuer, so custom domains are accepted
+// without allowing other pools or paths.
+func (c *Cognito) issuerSuffixMatches(iss string) bool {
+	if c.IssuerSuffix == "" {
+		return false
+	}
+	u, err := url.Parse(iss)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "https" && u.Host != "" && u.User == nil &&
+		u.RawQuery == "" && u.Fragment == "" && u.Path == c.IssuerSuffix
+}
+
+// verifyAudClientID checks aud contains client_id, tokens with only one of them are skipped
+func (c *Cognito) verifyAudClientID(token *jwt.Token) error {
+	if !c.AudClientIDConsistency {
+		return nil
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	clientId, ok := claims["client_id"].(string)
+	if !ok {
+		return nil
+	}
+	if _, ok := claims["aud"]; !ok {
+		return nil
+	}
+	if !containsString(audiences(claims), clientId) {
+		return fmt.Errorf("%w: client_id %s", ErrAudClientIDMismatch, clientId)
+	}
+	return nil
+}
+
+// verifyIssuerAudienceBinding checks every audience of the token, or client_id
+// for access tokens, is bound to its issuer in IssuerAudiences
+func (c *Cognito) verifyIssuerAudienceBinding(token *jwt.Token) error {
+	if len(c.IssuerAudiences) == 0 {
+		return nil
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	iss, _ := claims["iss"].(string)
+	allowed, ok := c.IssuerAudiences[iss]
+	if !ok {
+		return fmt.Errorf("%w: no audiences bound to %s", ErrIssuerAudienceBinding, iss)
+	}
+	auds := tokenAudiences(claims)
+	if len(auds) == 0 {
+		return fmt.Errorf("%w: token has no audience", ErrIssuerAudienceBinding)
+	}
+	for _, aud := range auds {
+		if aud == "" || !containsString(allowed, aud) {
+			return fmt.Errorf("%w: %q is not bound to %s", ErrIssuerAudienceBinding, aud, iss)
+		}
+	}
+	return nil
+}
+
+// tokenAudiences returns aud as a list, or client_id for access tokens
+func tokenAudiences(claims jwt.MapClaims) []string {
+	if tokenUse, _ := claims["token_use"].(string); tokenUse == "access" {
+		clientId, _ := claims["client_id"].(string)
+		return []string{clientId}
+	}
+	return audiences(claims)
+}
+
+// audiences returns aud, which may be a string or an array of strings, as a list
+func audiences(claims jwt.MapClaims) []string {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		auds := make([]string, 0, len(aud))
+		for _, a := range aud {
+			s, _ := a.(string)
+			auds = append(auds, s)
+		}
+		return auds
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
 	}
+	return false
+}
 
-	// verify expire time
-	if !token.Claims.(jwt.MapClaims).VerifyExpiresAt(time.Now().Unix(), true) {
-		return token, errors.New("token expired")
+func (c *Cognito) verifyTokenUsePresent(token *jwt.Token) error {
+	if !c.RequireTokenUse {
+		return nil
+	}
+	if tokenUse, _ := token.Claims.(jwt.MapClaims)["token_use"].(string); tokenUse == "" {
+		return ErrMissingTokenUse
 	}
+	return nil
+}
 
-	// verify issuer
-	if !token.Claims.(jwt.MapClaims).VerifyIssuer(c.Iss, true) {
-		return token, errors.New("iss is invalid")
+// verifyTokenUseAllowed checks token_use against AllowedTokenUse, tokens
+// without the claim are left to RequireTokenUse
+func (c *Cognito) verifyTokenUseAllowed(token *jwt.Token) error {
+	tokenUse, ok := token.Claims.(jwt.MapClaims)["token_use"].(string)
+	if !ok {
+		return nil
 	}
+	allowed := c.AllowedTokenUse
+	if len(allowed) == 0 {
+		allowed = defaultAllowedTokenUse
+	}
+	for _, use := range allowed {
+		if tokenUse == use {
+			return nil
+		}
+	}
+	return fmt.Errorf("token_use %q is not allowed", tokenUse)
+}
 
+func (c *Cognito) verifySub(token *jwt.Token) error {
+	if !c.ValidateSubUUID {
+		return nil
+	}
+	_, err := SubUUID(token)
+	return err
+}
+
+// VerifyIDToken verifies an id token from the OIDC auth-code flow. Tokens
+// whose token_use is not "id" are rejected. When expectedNonce is not empty
+// the token's nonce claim must match it.
+func (c *Cognito) VerifyIDToken(tokenStr, expectedNonce string) (*jwt.Token, error) {
+	token, err := c.VerifyToken(tokenStr)
+	if err != nil {
+		return token, err
+	}
+	if tokenUse, _ := token.Claims.(jwt.MapClaims)["token_use"].(string); tokenUse != "id" {
+		return token, fmt.Errorf("%w: token_use %q", ErrNotIDToken, tokenUse)
+	}
+	if expectedNonce == "" {
+		return token, nil
+	}
+	nonce, ok := token.Claims.(jwt.MapClaims)["nonce"].(string)
+	if !ok {
+		return token, fmt.Errorf("%w: missing nonce", ErrNonceMismatch)
+	}
+	if subtle.ConstantTimeCompare([]byte(nonce), []byte(expectedNonce)) != 1 {
+		return token, ErrNonceMismatch
+	}
 	return token, nil
 }
 
-func (c *Cognito) getCert(token *jwt.Token) (*rsa.PublicKey, error) {
-	kid := token.Header["kid"].(string)
-	key, ok := c.PublicKeys[kid]
+// VerifyTokenWithDeadlineContext verifies tokenStr and returns a child of ctx
+// whose deadline is the token's exp, so work started with it is cancelled
+// once the token expires. The returned cancel func must be called to release
+// the context's resources; it is nil when verification fails.
+func (c *Cognito) VerifyTokenWithDeadlineContext(ctx context.Context, tokenStr string) (context.Context, context.CancelFunc, *jwt.Token, error) {
+	token, err := c.VerifyTokenWithContext(ctx, tokenStr)
+	if err != nil {
+		return nil, nil, token, err
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	exp, ok := claimTime(claims, "exp")
+	deadline := time.Unix(exp, 0)
 	if !ok {
-		return nil, fmt.Errorf("invalid kid %s", kid)
+		// the token was accepted on iat + FallbackExpiry
+		deadline, _ = c.fallbackExpiry(claims)
 	}
+	deadlineCtx, cancel := context.WithDeadline(ctx, deadline)
+	return deadlineCtx, cancel, token, nil
+}
 
-	return key.PEM, nil
+// verifyExpiry checks exp, falling back to iat + FallbackExpiry for tokens without exp if configured
+func (c *Cognito) verifyExpiry(token *jwt.Token) error {
+	claims := token.Claims.(jwt.MapClaims)
+	now := c.now().Add(-c.Leeway)
+	expired := false
+	if _, hasExp := claims["exp"]; hasExp || c.FallbackExpiry <= 0 {
+		expired = !claims.VerifyExpiresAt(now.Unix(), true)
+	} else if expiry, ok := c.fallbackExpiry(claims); ok {
+		expired = !now.Before(expiry)
+	} else {
+		expired = true
+	}
+	if expired {
+		return ErrTokenExpired
+	}
+	return nil
 }
 
-func getPublicKeys(iss string) (PublicKeys, error) {
-	client := &http.Client{
-		Timeout: time.Second * time.Duration(10),
+// fallbackExpiry returns iat + FallbackExpiry, the expiry of tokens without exp
+func (c *Cognito) fallbackExpiry(claims jwt.MapClaims) (time.Time, bool) {
+	if c.FallbackExpiry <= 0 {
+		return time.Time{}, false
 	}
-	resp, err := client.Get(iss)
+	iat, ok := claimTime(claims, "iat")
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(iat, 0).Add(c.FallbackExpiry), true
+}
+
+func (c *Cognito) verifyIssuedAt(token *jwt.Token) error {
+	if !token.Claims.(jwt.MapClaims).VerifyIssuedAt(c.now().Add(c.Leeway).Unix(), false) {
+		return ErrTokenUsedBeforeIssued
+	}
+	return nil
+}
+
+func (c *Cognito) verifyNotBefore(token *jwt.Token) error {
+	if !token.Claims.(jwt.MapClaims).VerifyNotBefore(c.now().Add(c.Leeway).Unix(), false) {
+		return ErrTokenNotValidYet
+	}
+	return nil
+}
+
+// verifyStrict requires the claims whose checks are skipped when they are missing
+func (c *Cognito) verifyStrict(token *jwt.Token) error {
+	if !c.Strict {
+		return nil
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	required := []string{"token_use", "iss", "exp", "iat", "aud"}
+	if tokenUse, _ := claims["token_use"].(string); tokenUse == "access" {
+		// access tokens carry the app client in client_id instead of aud
+		required[len(required)-1] = "client_id"
+	}
+	for _, name := range required {
+		if v, ok := claims[name]; !ok || v == nil || v == "" {
+			return fmt.Errorf("%w %s", ErrMissingClaim, name)
+		}
+	}
+	return nil
+}
+
+// verifyStrictOIDC checks the claims OIDC Core requires in an id token are
+// present and correctly typed
+func (c *Cognito) verifyStrictOIDC(token *jwt.Token) error {
+	if !c.StrictOIDC {
+		return nil
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	for _, name := range []string{"iss", "sub", "aud", "exp", "iat"} {
+		if v, ok := claims[name]; !ok || v == nil {
+			return fmt.Errorf("%w %s", ErrMissingClaim, name)
+		}
+	}
+	for _, name := range []string{"iss", "sub"} {
+		if v, ok := claims[name].(string); !ok || v == "" {
+			return fmt.Errorf("claim %s must be a non-empty string", name)
+		}
+	}
+	if !isAudience(claims["aud"]) {
+		return errors.New("claim aud must be a string or an array of strings")
+	}
+	for _, name := range []string{"exp", "iat"} {
+		if _, ok := claimTime(claims, name); !ok {
+			return fmt.Errorf("claim %s must be a number", name)
+		}
+	}
+	return nil
+}
+
+// isAudience reports whether v is a non-empty aud value as defined by RFC 7519
+func isAudience(v interface{}) bool {
+	switch aud := v.(type) {
+	case string:
+		return aud != ""
+	case []interface{}:
+		if len(aud) == 0 {
+			return false
+		}
+		for _, a := range aud {
+			if s, ok := a.(string); !ok || s == "" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (c *Cognito) verifyRequiredClaims(token *jwt.Token) error {
+	claims := token.Claims.(jwt.MapClaims)
+	for _, name := range c.RequiredClaims {
+		v, ok := claims[name]
+		if !ok || v == nil {
+			return fmt.Errorf("%w %s", ErrMissingClaim, name)
+		}
+		if str, isStr := v.(string); isStr && str == "" && c.RejectEmptyClaims {
+			return fmt.Errorf("%w %s: empty value", ErrMissingClaim, name)
+		}
+	}
+	return nil
+}
+
+// verifyNotRevoked checks origin_jti, tokens without the claim can't be matched and are accepted
+func (c *Cognito) verifyNotRevoked(token *jwt.Token) error {
+	if c.OriginJTIRevocationChecker == nil {
+		return nil
+	}
+	originJTI, ok := OriginJTI(token)
+	if !ok {
+		return nil
+	}
+	revoked, err := c.OriginJTIRevocationChecker.IsRevoked(originJTI)
+	if err != nil {
+		return fmt.Errorf("check revocation of origin_jti %s: %w", originJTI, err)
+	}
+	if revoked {
+		return fmt.Errorf("%w: origin_jti %s", ErrTokenRevoked, originJTI)
+	}
+	return nil
+}
+
+func (c *Cognito) verifyLifetime(token *jwt.Token) error {
+	if c.MaxTokenLifetime <= 0 {
+		return nil
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	exp, ok := claimTime(claims, "exp")
+	if !ok {
+		return fmt.Errorf("%w: missing exp", ErrTokenLifetimeTooLong)
+	}
+	iat, ok := claimTime(claims, "iat")
+	if !ok {
+		return fmt.Errorf("%w: missing iat", ErrTokenLifetimeTooLong)
+	}
+	if lifetime := time.Duration(exp-iat) * time.Second; lifetime > c.MaxTokenLifetime {
+		return fmt.Errorf("%w: %s exceeds %s", ErrTokenLifetimeTooLong, lifetime, c.MaxTokenLifetime)
+	}
+	return nil
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// SubUUID returns the sub claim of token in canonical lowercase UUID form.
+// It fails with ErrInvalidSub when the claim is missing or not a UUID.
+func SubUUID(token *jwt.Token) (string, error) {
+	claims, _ := token.Claims.(jwt.MapClaims)
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return "", fmt.Errorf("%w: missing sub", ErrInvalidSub)
+	}
+	if !uuidPattern.MatchString(sub) {
+		return "", fmt.Errorf("%w: %s is not a UUID", ErrInvalidSub, sub)
+	}
+	return strings.ToLower(sub), nil
+}
+
+// OriginJTI returns the origin_jti claim, which Cognito shares between all
+// tokens issued from the same refresh token.
+func OriginJTI(token *jwt.Token) (string, bool) {
+	claims, _ := token.Claims.(jwt.MapClaims)
+	originJTI, ok := claims["origin_jti"].(string)
+	return originJTI, ok && originJTI != ""
+}
+
+// claimTime reads a NumericDate claim as unix seconds
+func claimTime(claims jwt.MapClaims, name string) (int64, bool) {
+	switch v := claims[name].(type) {
+	case float64:
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// TimeUntilExpiry returns how long until token expires, or 0 if it has
+// already expired. It never returns a negative duration.
+func (c *Cognito) TimeUntilExpiry(token *jwt.Token) (time.Duration, error) {
+	claims, _ := token.Claims.(jwt.MapClaims)
+	exp, ok := claimTime(claims, "exp")
+	if !ok {
+		return 0, errors.New("token has no exp")
+	}
+	if d := time.Unix(exp, 0).Sub(c.now()); d > 0 {
+		return d, nil
+	}
+	return 0, nil
+}
+
+// ExpiresInSeconds returns the number of whole seconds until token expires,
+// or 0 if it has already expired.
+func (c *Cognito) ExpiresInSeconds(token *jwt.Token) (int64, error) {
+	d, err := c.TimeUntilExpiry(token)
+	if err != nil {
+		return 0, err
+	}
+	return int64(d / time.Second), nil
+}
+
+// KeyFingerprint returns the hex SHA-256 of the DER encoded public key that
+// verified token, so logs can reference the key independently of its kid.
+// token must come from VerifyToken. The key is looked up in the loaded keys,
+// they are never refetched.
+func (c *Cognito) KeyFingerprint(token *jwt.Token) (string, error) {
+	if token == nil || !token.Valid {
+		return "", errors.New("token is not verified")
+	}
+	var key PublicKey
+	if _, hasKid := token.Header["kid"]; !hasKid && hasThumbprint(token) {
+		var err error
+		if key, err = c.getKeyByThumbprint(token); err != nil {
+			return "", err
+		}
+	} else {
+		kid, err := headerKid(token)
+		if err != nil {
+			return "", err
+		}
+		var ok bool
+		if key, ok = c.publicKey(kid); !ok {
+			return "", fmt.Errorf("%w %s", ErrInvalidKid, kid)
+		}
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.PEM)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *Cognito) allowedAlgs() []string {
+	if len(c.AllowedAlgs) == 0 {
+		return defaultAllowedAlgs
+	}
+	return c.AllowedAlgs
+}
+
+func (c *Cognito) now() time.Time {
+	if c.TimeFunc != nil {
+		return c.TimeFunc()
+	}
+	return time.Now()
+}
+
+// GetKeys returns a copy of the loaded public keys so callers can inspect them
+// without being able to change the keys used for verification.
+// The *rsa.PublicKey values are shared with the verifier and must not be modified.
+func (c *Cognito) GetKeys() PublicKeys {
+	c.keysMu.RLock()
+	defer c.keysMu.RUnlock()
+	keys := make(PublicKeys, len(c.PublicKeys))
+	for kid, key := range c.PublicKeys {
+		keys[kid] = key
+	}
+	return keys
+}
+
+func (c *Cognito) getCert(ctx context.Context, token *jwt.Token) (*rsa.PublicKey, error) {
+	key, err := c.getKey(ctx, token, nil)
 	if err != nil {
 		return nil, err
 	}
+	return key.PEM, nil
+}
+
+// getKey finds the signing key for token by kid, or by thumbprint when it has no kid.
+// Kids are looked up in keys first when it isn't nil.
+func (c *Cognito) getKey(ctx context.Context, token *jwt.Token, keys PublicKeys) (PublicKey, error) {
+	// providers that identify keys by certificate thumbprint omit kid
+	if _, hasKid := token.Header["kid"]; !hasKid && hasThumbprint(token) {
+		return c.getKeyByThumbprint(token)
+	}
+
+	kid, err := headerKid(token)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	key, ok := keys[kid]
+	if !ok {
+		key, ok = c.publicKey(kid)
+	}
+	if !ok {
+		c.logger().Debugf("cognito: kid %s is not cached", kid)
+	}
+	if !ok && c.refreshKeys(ctx) {
+		// the pool may have rotated its keys since they were loaded
+		key, ok = c.publicKey(kid)
+	}
+	if !ok {
+		return PublicKey{}, fmt.Errorf("%w %s", ErrInvalidKid, kid)
+	}
+	if !isSigningKey(key) {
+		return PublicKey{}, fmt.Errorf("%w %s: use is %s", ErrInvalidKid, kid, key.Use)
+	}
+	if !c.kidAllowed(kid) {
+		return PublicKey{}, fmt.Errorf("%w: %s", ErrKIDNotAllowed, kid)
+	}
+	if err := verifyKeyAlg(key, token); err != nil {
+		return PublicKey{}, err
+	}
+
+	return key, nil
+}
+
+// isSigningKey reports whether key may verify signatures, keys without a use are assumed to be
+func isSigningKey(key PublicKey) bool {
+	return key.Use == "" || key.Use == "sig"
+}
+
+// verifyKeyAlg rejects keys whose JWKS alg disagrees with the token's signing method,
+// keys without an alg may be used with any method
+func verifyKeyAlg(key PublicKey, token *jwt.Token) error {
+	if key.Alg == "" || token.Method == nil {
+		return nil
+	}
+	if alg := token.Method.Alg(); key.Alg != alg {
+		return fmt.Errorf("%w: kid %s is for %s, token is signed with %s", ErrKeyAlgMismatch, key.Kid, key.Alg, alg)
+	}
+	return nil
+}
+
+// refreshKeys refetches the JWKS from JWKSURL, at most once per
+// jwksRefreshInterval, and reports whether the keys were replaced
+func (c *Cognito) refreshKeys(ctx context.Context) bool {
+	if c.JWKSURL == "" || c.PinnedJWKS != nil {
+		return false
+	}
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	now := c.now()
+	if !c.lastRefresh.IsZero() && now.Sub(c.lastRefresh) < jwksRefreshInterval {
+		return false
+	}
+	c.lastRefresh = now
+
+	if err := c.reloadKeys(ctx); err != nil {
+		if ctx.Err() != nil {
+			// a cancelled caller shouldn't hold back the next refresh
+			c.lastRefresh = time.Time{}
+		}
+		return false
+	}
+	return true
+}
+
+// reloadKeys fetches the JWKS and replaces the loaded keys, keeping them on error
+func (c *Cognito) reloadKeys(ctx context.Context) error {
+	if c.PinnedJWKS != nil {
+		return nil
+	}
+	publicKeys, expiry, err := c.getPublicKeys(ctx, c.JWKSURL)
+	if err != nil {
+		return err
+	}
+	c.setPublicKeys(publicKeys, expiry)
+	c.logger().Debugf("cognito: refreshed keys from %s", c.JWKSURL)
+	return nil
+}
+
+// RefreshKeys refetches the JWKS from JWKSURL and swaps in the new keys, for
+// when keys are rotated out of band. On error the current keys are kept.
+// Pinned keys are never refreshed.
+func (c *Cognito) RefreshKeys() error {
+	if c.JWKSURL == "" && c.PinnedJWKS == nil {
+		return errors.New("no JWKS URL to refresh from")
+	}
+	return c.reloadKeys(context.Background())
+}
+
+// StartKeyRefresh refetches the JWKS from JWKSURL every interval in the
+// background, skipping ticks while the keys are fresh according to
+// KeysExpiry. A failed refresh keeps the previous keys and is passed to
+// OnKeyRefreshError. An interval that is not positive falls back to one
+// minute. The returned function stops the refresh and waits for it to exit,
+// it is safe to call more than once.
+func (c *Cognito) StartKeyRefresh(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultKeyRefreshInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if c.now().Before(c.KeysExpiry()) {
+					continue
+				}
+				// a fetch interrupted by stop isn't a refresh failure
+				if err := c.reloadKeys(ctx); err != nil && ctx.Err() == nil && c.OnKeyRefreshError != nil {
+					c.OnKeyRefreshError(err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-exited
+		})
+	}
+}
+
+func (c *Cognito) publicKey(kid string) (PublicKey, bool) {
+	c.keysMu.RLock()
+	defer c.keysMu.RUnlock()
+	key, ok := c.PublicKeys[kid]
+	return key, ok
+}
+
+func (c *Cognito) setPublicKeys(publicKeys PublicKeys, expiry time.Time) {
+	c.keysMu.Lock()
+	defer c.keysMu.Unlock()
+	c.PublicKeys = publicKeys
+	c.keysExpiry = expiry
+	c.keysFetched = c.now()
+}
+
+// Ready reports whether the client can verify tokens, for readiness probes.
+// It fails with ErrNoKeys when no keys are loaded, e.g. after the JWKS came
+// back empty, and with ErrStaleKeys when MaxKeyStaleness is set and the keys
+// haven't been fetched successfully within it.
+func (c *Cognito) Ready() error {
+	c.keysMu.RLock()
+	defer c.keysMu.RUnlock()
+	if len(c.PublicKeys) == 0 {
+		return ErrNoKeys
+	}
+	if c.MaxKeyStaleness > 0 && c.PinnedJWKS == nil {
+		if age := c.now().Sub(c.keysFetched); age > c.MaxKeyStaleness {
+			return fmt.Errorf("%w: last fetched %s ago", ErrStaleKeys, age)
+		}
+	}
+	return nil
+}
+
+// KeysExpiry returns when the loaded keys go stale, derived from the
+// Cache-Control max-age or Expires header of the JWKS response and one hour
+// after the fetch without them. It is zero for keys that weren't fetched.
+func (c *Cognito) KeysExpiry() time.Time {
+	c.keysMu.RLock()
+	defer c.keysMu.RUnlock()
+	return c.keysExpiry
+}
+
+func hasThumbprint(token *jwt.Token) bool {
+	_, hasX5t := token.Header["x5t"]
+	_, hasX5tS256 := token.Header["x5t#S256"]
+	return hasX5t || hasX5tS256
+}
+
+// getKeyByThumbprint finds the key whose x5t#S256 or x5t matches the token header
+func (c *Cognito) getKeyByThumbprint(token *jwt.Token) (PublicKey, error) {
+	x5tS256, _ := token.Header["x5t#S256"].(string)
+	x5t, _ := token.Header["x5t"].(string)
+	c.keysMu.RLock()
+	defer c.keysMu.RUnlock()
+	for _, key := range c.PublicKeys {
+		if !isSigningKey(key) {
+			continue
+		}
+		if (x5tS256 != "" && key.X5tS256 == x5tS256) || (x5t != "" && key.X5t == x5t) {
+			if !c.kidAllowed(key.Kid) {
+				return PublicKey{}, fmt.Errorf("%w: %s", ErrKIDNotAllowed, key.Kid)
+			}
+			if err := verifyKeyAlg(key, token); err != nil {
+				return PublicKey{}, err
+			}
+			return key, nil
+		}
+	}
+	if x5tS256 != "" {
+		return PublicKey{}, fmt.Errorf("invalid x5t#S256 %s", x5tS256)
+	}
+	return PublicKey{}, fmt.Errorf("invalid x5t %s", x5t)
+}
+
+// headerKid returns the kid header as a string, accepting numeric kids from non-conformant issuers
+func headerKid(token *jwt.Token) (string, error) {
+	switch kid := token.Header["kid"].(type) {
+	case string:
+		return kid, nil
+	case float64:
+		return strconv.FormatFloat(kid, 'f', -1, 64), nil
+	case json.Number:
+		return kid.String(), nil
+	case int:
+		return strconv.Itoa(kid), nil
+	case int64:
+		return strconv.FormatInt(kid, 10), nil
+	case nil:
+		return "", errors.New("token header missing kid")
+	default:
+		return "", fmt.Errorf("%w type %T", ErrInvalidKid, kid)
+	}
+}
+
+// kidAllowed reports whether kid may be used, all kids are allowed when no allowlist is set
+func (c *Cognito) kidAllowed(kid string) bool {
+	if len(c.AllowedKIDs) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedKIDs {
+		if kid == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// getPublicKeys fetches the JWKS, returning the keys and when they go stale
+func (c *Cognito) getPublicKeys(ctx context.Context, iss string) (PublicKeys, time.Time, error) {
+	delay := c.RetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		var publicKeys PublicKeys
+		var expiry time.Time
+		var err error
+		if c.Tracer != nil {
+			publicKeys, expiry, err = c.traceGetPublicKeys(ctx, iss)
+		} else {
+			publicKeys, expiry, err = c.fetchPublicKeys(ctx, iss)
+		}
+		if err == nil {
+			c.logger().Debugf("cognito: fetched %d keys from %s", len(publicKeys), iss)
+			return publicKeys, expiry, nil
+		}
+		if attempt >= c.RetryAttempts || !retryableFetchError(err) {
+			c.logger().Errorf("cognito: fetching JWKS from %s: %v", iss, err)
+			return publicKeys, expiry, err
+		}
+
+		c.logger().Debugf("cognito: retrying JWKS fetch from %s in %s: %v", iss, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			c.logger().Errorf("cognito: fetching JWKS from %s: %v", iss, err)
+			return nil, time.Time{}, err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// statusError is returned for non 2xx JWKS responses
+type statusError struct {
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected JWKS response status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// retryableFetchError reports whether a JWKS fetch failed transiently, i.e.
+// with a connection error or a 5xx response
+func retryableFetchError(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func (c *Cognito) fetchPublicKeys(ctx context.Context, iss string) (PublicKeys, time.Time, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{
+			Timeout: defaultHTTPTimeout,
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, iss, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	// ask for gzip explicitly so compressed responses are decoded the same way
+	// regardless of whether the transport decompresses transparently
+	req.Header.Set("Accept-Encoding", "gzip")
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if c.RequestSigner != nil {
+		if err := c.RequestSigner(req); err != nil {
+			return nil, time.Time{}, fmt.Errorf("sign JWKS request: %w", err)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
 	defer resp.Body.Close()
-	respJson := struct {
-		Keys []PublicKey `json:"keys"`
-	}{}
-	if err := json.NewDecoder(resp.Body).Decode(&respJson); err != nil {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, time.Time{}, &statusError{StatusCode: resp.StatusCode}
+	}
+
+	body := io.Reader(resp.Body)
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	publicKeys, err := c.parseJWKS(body)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return publicKeys, cacheExpiry(resp.Header, c.now()), nil
+}
+
+// cacheExpiry derives when a response goes stale from its Cache-Control
+// max-age, then its Expires header, defaulting to defaultJWKSMaxAge
+func cacheExpiry(h http.Header, now time.Time) time.Time {
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value := directive, ""
+		if i := strings.Index(directive, "="); i >= 0 {
+			name, value = directive[:i], directive[i+1:]
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "max-age") {
+			if seconds, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`)); err == nil && seconds >= 0 {
+				return now.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	if expires, err := http.ParseTime(h.Get("Expires")); err == nil {
+		return expires
+	}
+	return now.Add(defaultJWKSMaxAge)
+}
+
+// jwks is the JSON Web Key Set document served by Cognito
+type jwks struct {
+	Keys []PublicKey `json:"keys"`
+}
+
+// ParseJWKS decodes a JWKS document into a key map without any network call.
+// Keys for algorithms other than RS256 and keys whose use is not sig are skipped,
+// use NewCognitoClientFromJWKS with WithAllowedAlgs to keep keys for other algorithms.
+func ParseJWKS(jwks []byte) (PublicKeys, error) {
+	return (&Cognito{}).parseJWKS(bytes.NewReader(jwks))
+}
+
+// parseJWKS decodes a JWKS document into a key map, applying the client's key policies
+func (c *Cognito) parseJWKS(r io.Reader) (PublicKeys, error) {
+	respJson := jwks{}
+	if err := json.NewDecoder(r).Decode(&respJson); err != nil {
 		return nil, err
 	}
 
 	// iterate through list of keys and assign them to key map
 	publicKeys := make(map[string]PublicKey)
 	for _, key := range respJson.Keys {
+		// skip keys for algorithms the verifier can't use so getCert only returns usable keys
+		if key.Alg != "" && !containsString(c.allowedAlgs(), key.Alg) {
+			c.logger().Debugf("cognito: skipping kid %s with unsupported alg %s", key.Kid, key.Alg)
+			continue
+		}
+		// encryption keys may share the set with signing keys
+		if !isSigningKey(key) {
+			c.logger().Debugf("cognito: skipping kid %s with use %s", key.Kid, key.Use)
+			continue
+		}
 		if pem, err := parsePEM(key); err != nil {
 			return nil, err
 		} else {
 			key.PEM = pem
 		}
+		// skip only the weak key, so the rest of the set still loads and rotates
+		if bits := key.PEM.N.BitLen(); bits < c.MinRSABits {
+			c.logger().Errorf("cognito: skipping kid %s: %v", key.Kid, fmt.Errorf("%w: %d bits, minimum is %d", ErrWeakKey, bits, c.MinRSABits))
+			continue
+		}
 		publicKeys[key.Kid] = key
 	}
 	return publicKeys, nil
 }
 
+// ExportJWKS serializes the loaded keys as a JWKS document, so the service
+// can act as a JWKS mirror. n and e are encoded from the parsed keys.
+func (c *Cognito) ExportJWKS() ([]byte, error) {
+	keys := c.GetKeys()
+	doc := jwks{Keys: make([]PublicKey, 0, len(keys))}
+	for _, key := range keys {
+		if key.PEM == nil {
+			return nil, fmt.Errorf("kid %s has no parsed key", key.Kid)
+		}
+		key.Kty = "RSA"
+		key.N = base64.RawURLEncoding.EncodeToString(key.PEM.N.Bytes())
+		key.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PEM.E)).Bytes())
+		doc.Keys = append(doc.Keys, key)
+	}
+	// sort for stable output
+	sort.Slice(doc.Keys, func(i, j int) bool {
+		return doc.Keys[i].Kid < doc.Keys[j].Kid
+	})
+	return json.Marshal(doc)
+}
+
 func parsePEM(k PublicKey) (*rsa.PublicKey, error) {
 	if k.Kty != "RSA" {
 		return nil, fmt.Errorf("KTY %s must be RSA", k.Kty)
@@ -149,11 +1625,9 @@ func parsePEM(k PublicKey) (*rsa.PublicKey, error) {
 		return nil, err
 	}
 
-	e := 0
-	if k.E == "AQAB" || k.E == "AAEAAQ" {
-		e = 65537
-	} else {
-		return nil, fmt.Errorf("E %s is invalid", k.E)
+	e, err := parseExponent(k.E)
+	if err != nil {
+		return nil, err
 	}
 
 	return &rsa.PublicKey{
@@ -161,3 +1635,38 @@ func parsePEM(k PublicKey) (*rsa.PublicKey, error) {
 		E: e,
 	}, nil
 }
+
+// parseExponent decodes a base64url big-endian RSA public exponent, which
+// must be odd, greater than one and fit in an int
+func parseExponent(s string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, fmt.Errorf("E %s is invalid", s)
+	}
+	e := new(big.Int).SetBytes(b)
+	if e.Cmp(big.NewInt(1)) <= 0 || e.Bit(0) == 0 || e.BitLen() > 31 {
+		return 0, fmt.Errorf("E %s is invalid", s)
+	}
+	return int(e.Int64()), nil
+}
+
+// multiError combines the failures of several claim checks
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the combined errors matches target
+func (m multiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
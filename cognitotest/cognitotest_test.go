@@ -0,0 +1,102 @@
+package cognitotest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hiepd/cognito-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKid      = "testkid"
+	testClientId = "xxxxxxxxxxxxexample"
+	testIss      = "https://cognito-idp.ap-southeast-2.amazonaws.com/ap-southeast-2_example"
+)
+
+func TestSignToken(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	c := NewTestCognito(testClientId, testIss, &privateKey.PublicKey, testKid)
+
+	now := time.Now()
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"sub":              "aaaaaaaa-bbbb-cccc-dddd-example",
+			"aud":              testClientId,
+			"iss":              testIss,
+			"token_use":        "id",
+			"cognito:username": "anaya",
+			"exp":              now.Add(time.Hour).Unix(),
+			"iat":              now.Unix(),
+		}
+		for k, v := range overrides {
+			claims[k] = v
+		}
+		return claims
+	}
+	tests := []struct {
+		name    string
+		key     *rsa.PrivateKey
+		kid     string
+		claims  jwt.MapClaims
+		wantErr error
+	}{
+		{
+			name:   "Valid",
+			key:    privateKey,
+			kid:    testKid,
+			claims: claims(nil),
+		},
+		{
+			name:    "Expired",
+			key:     privateKey,
+			kid:     testKid,
+			claims:  claims(jwt.MapClaims{"exp": now.Add(-time.Hour).Unix()}),
+			wantErr: cognito.ErrTokenExpired,
+		},
+		{
+			name:    "Other audience",
+			key:     privateKey,
+			kid:     testKid,
+			claims:  claims(jwt.MapClaims{"aud": "other"}),
+			wantErr: cognito.ErrInvalidAudience,
+		},
+		{
+			name:    "Unknown kid",
+			key:     privateKey,
+			kid:     "other",
+			claims:  claims(nil),
+			wantErr: cognito.ErrInvalidKid,
+		},
+		{
+			name:    "Other key",
+			key:     other,
+			kid:     testKid,
+			claims:  claims(nil),
+			wantErr: cognito.ErrInvalidSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenStr, err := SignToken(tt.key, tt.kid, tt.claims)
+			require.NoError(t, err)
+
+			token, err := c.VerifyToken(tokenStr)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testKid, token.Header["kid"])
+			assert.Equal(t, "anaya", token.Claims.(jwt.MapClaims)["cognito:username"])
+		})
+	}
+}
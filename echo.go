@@ -0,0 +1,43 @@
+//go:build echo
+// +build echo
+
+package cognito
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// EchoMiddleware is the echo counterpart of Authorize. It verifies the bearer
+// token and sets the token and username in the echo context, under the keys
+// from ContextKeys. Failures return a 403 echo.HTTPError with the same
+// messages as Authorize.
+//
+// It is only built with the echo build tag, so the echo dependency stays optional.
+func (cog *Cognito) EchoMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tokenStr, err := cog.tokenFromRequest(c.Request())
+			if err != nil {
+				return cog.echoForbidden(c, "invalid Authorization header")
+			}
+			token, err := cog.VerifyTokenWithContext(c.Request().Context(), tokenStr)
+			if err != nil {
+				return cog.echoForbidden(c, "invalid token")
+			}
+			keys := cog.contextKeys()
+			c.Set(keys.Token, token)
+			c.Set(keys.Username, tokenUsername(token))
+			return next(c)
+		}
+	}
+}
+
+// echoForbidden returns the 403 error, adding the configured CORS headers
+func (cog *Cognito) echoForbidden(c echo.Context, message string) error {
+	for k, v := range cog.ErrorCORSHeaders {
+		c.Response().Header().Set(k, v)
+	}
+	return echo.NewHTTPError(http.StatusForbidden, message)
+}
@@ -0,0 +1,76 @@
+//go:build echo
+// +build echo
+
+package cognito
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCognito_EchoMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			header:   "Bearer " + signTestToken(t, testClaims(nil)),
+			wantCode: http.StatusOK,
+			wantBody: "anaya",
+		},
+		{
+			name:     "No token",
+			header:   "",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"message":"invalid Authorization header"}`,
+		},
+		{
+			name:     "Invalid token",
+			header:   "Bearer " + signTestToken(t, testClaims(jwt.MapClaims{"aud": "other"})),
+			wantCode: http.StatusForbidden,
+			wantBody: `{"message":"invalid token"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cog := newTestCognito(t, WithErrorCORSHeaders(map[string]string{"Access-Control-Allow-Origin": "https://app.example.com"}))
+			e := echo.New()
+			e.GET("/user", func(c echo.Context) error {
+				_, ok := c.Get("token").(*jwt.Token)
+				assert.True(t, ok)
+				return c.String(http.StatusOK, c.Get("username").(string))
+			}, cog.EchoMiddleware())
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			e.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+				return
+			}
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+
+			// failures match the gin middleware
+			r := gin.New()
+			r.GET("/user", cog.Authorize)
+			ginW := httptest.NewRecorder()
+			r.ServeHTTP(ginW, req)
+			assert.Equal(t, ginW.Code, w.Code)
+			assert.JSONEq(t, ginW.Body.String(), w.Body.String())
+			assert.Equal(t, ginW.Header().Get("Access-Control-Allow-Origin"), w.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
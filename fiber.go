@@ -0,0 +1,64 @@
+//go:build fiber
+// +build fiber
+
+package cognito
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber/v2"
+)
+
+// FiberMiddleware is the fiber counterpart of Authorize. It verifies the token
+// read by the configured TokenExtractor and stores the token, email, username
+// and groups in c.Locals under the keys from ContextKeys. Failures get the
+// same 403 JSON response as Authorize.
+//
+// It is only built with the fiber build tag, so the fiber dependency stays optional.
+func (cog *Cognito) FiberMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenStr, err := cog.fiberToken(c)
+		if err != nil {
+			return cog.fiberForbidden(c, "invalid Authorization header")
+		}
+		token, err := cog.VerifyToken(tokenStr)
+		if err != nil {
+			return cog.fiberForbidden(c, "invalid token")
+		}
+		claims, _ := token.Claims.(jwt.MapClaims)
+		keys := cog.contextKeys()
+		c.Locals(keys.Token, token)
+		c.Locals(keys.Email, claims["email"])
+		c.Locals(keys.Username, tokenUsername(token))
+		c.Locals(keys.Groups, tokenGroups(token))
+		return c.Next()
+	}
+}
+
+// fiberToken reads the token with the configured TokenExtractor. fiber runs on
+// fasthttp, so the extractor is given an *http.Request holding a copy of the
+// method, URL and headers.
+func (cog *Cognito) fiberToken(c *fiber.Ctx) (string, error) {
+	if cog.TokenExtractor == nil {
+		return bearerToken(c.Get(fiber.HeaderAuthorization))
+	}
+	u, err := url.ParseRequestURI(c.OriginalURL())
+	if err != nil {
+		return "", err
+	}
+	r := &http.Request{Method: c.Method(), URL: u, Header: http.Header{}, Host: c.Hostname()}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		r.Header.Add(string(key), string(value))
+	})
+	return cog.TokenExtractor(r)
+}
+
+// fiberForbidden writes the same response as abort for fiber handlers
+func (cog *Cognito) fiberForbidden(c *fiber.Ctx, message string) error {
+	for k, v := range cog.ErrorCORSHeaders {
+		c.Set(k, v)
+	}
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": message})
+}
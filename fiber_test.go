@@ -0,0 +1,123 @@
+//go:build fiber
+// +build fiber
+
+package cognito
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCognito_FiberMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			header:   "Bearer " + signTestToken(t, testClaims(nil)),
+			wantCode: http.StatusOK,
+			wantBody: "anaya",
+		},
+		{
+			name:     "No token",
+			header:   "",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"message":"invalid Authorization header"}`,
+		},
+		{
+			name:     "Invalid token",
+			header:   "Bearer " + signTestToken(t, testClaims(jwt.MapClaims{"aud": "other"})),
+			wantCode: http.StatusForbidden,
+			wantBody: `{"message":"invalid token"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cog := newTestCognito(t, WithErrorCORSHeaders(map[string]string{"Access-Control-Allow-Origin": "https://app.example.com"}))
+			app := fiber.New()
+			app.Get("/user", cog.FiberMiddleware(), func(c *fiber.Ctx) error {
+				_, ok := c.Locals("token").(*jwt.Token)
+				assert.True(t, ok)
+				assert.Equal(t, []string{}, c.Locals("groups"))
+				return c.SendString(c.Locals("username").(string))
+			})
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+			assert.Equal(t, tt.wantBody, string(body))
+
+			// failures match the gin middleware
+			if tt.wantCode != http.StatusOK {
+				r := gin.New()
+				r.GET("/user", cog.Authorize)
+				ginW := httptest.NewRecorder()
+				r.ServeHTTP(ginW, req)
+				assert.Equal(t, ginW.Code, resp.StatusCode)
+				assert.Equal(t, ginW.Body.String(), string(body))
+				assert.Equal(t, ginW.Header().Get("Access-Control-Allow-Origin"), resp.Header.Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
+
+func TestCognito_FiberMiddleware_ContextKeys(t *testing.T) {
+	cog := newTestCognito(t, WithContextKeys(ContextKeys{Token: "jwt", Username: "user"}))
+	app := fiber.New()
+	app.Get("/user", cog.FiberMiddleware(), func(c *fiber.Ctx) error {
+		_, ok := c.Locals("jwt").(*jwt.Token)
+		assert.True(t, ok)
+		assert.Nil(t, c.Locals("token"))
+		assert.Equal(t, []string{}, c.Locals("groups"))
+		return c.SendString(c.Locals("user").(string))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, testClaims(nil)))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "anaya", string(body))
+}
+
+func TestCognito_FiberMiddleware_TokenExtractor(t *testing.T) {
+	cog := newTestCognito(t, WithTokenExtractor(FromCookie("id_token")))
+	app := fiber.New()
+	app.Get("/user", cog.FiberMiddleware(), func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("username").(string))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/user?x=1", nil)
+	req.AddCookie(&http.Cookie{Name: "id_token", Value: signTestToken(t, testClaims(nil))})
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "anaya", string(body))
+
+	// the Authorization header is ignored once another extractor is configured
+	req = httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, testClaims(nil)))
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -5,6 +5,9 @@ go 1.15
 require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/gin-gonic/gin v1.6.3
-	github.com/golang/mock v1.4.4 // indirect
+	github.com/gofiber/fiber/v2 v2.2.0
+	github.com/golang/mock v1.4.4
+	github.com/labstack/echo/v4 v4.1.17
 	github.com/stretchr/testify v1.6.1
+	google.golang.org/grpc v1.33.2
 )
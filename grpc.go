@@ -0,0 +1,67 @@
+//go:build grpc
+// +build grpc
+
+package cognito
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor verifies the bearer token in the authorization
+// metadata and stores it in the context, see TokenFromContext. Failures return
+// a codes.Unauthenticated status.
+//
+// It is only built with the grpc build tag, so the grpc dependency stays optional.
+func (cog *Cognito) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := cog.authorizeContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func (cog *Cognito) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := cog.authorizeContext(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorizeContext verifies the token in the incoming metadata and returns ctx with the token added
+func (cog *Cognito) authorizeContext(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var authHeader string
+	if values := md.Get("authorization"); len(values) > 0 {
+		authHeader = values[0]
+	}
+	tokenStr, err := bearerToken(authHeader)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata")
+	}
+	token, err := cog.VerifyTokenWithContext(ctx, tokenStr)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return contextWithToken(ctx, token), nil
+}
+
+// authorizedStream overrides the stream context with one carrying the verified token
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
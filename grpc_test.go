@@ -0,0 +1,110 @@
+//go:build grpc
+// +build grpc
+
+package cognito
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startTestGRPCServer serves the health service behind the interceptors, passing
+// the username of each verified token to usernames
+func startTestGRPCServer(t *testing.T, cog *Cognito, usernames chan<- interface{}) (healthpb.HealthClient, func()) {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(cog.UnaryServerInterceptor(), func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			token, _ := TokenFromContext(ctx)
+			usernames <- tokenUsername(token)
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(cog.StreamServerInterceptor(), func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			token, _ := TokenFromContext(ss.Context())
+			usernames <- tokenUsername(token)
+			return handler(srv, ss)
+		}),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	require.NoError(t, err)
+	return healthpb.NewHealthClient(conn), func() {
+		conn.Close()
+		srv.Stop()
+	}
+}
+
+func TestCognito_ServerInterceptors(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantCode codes.Code
+		wantMsg  string
+	}{
+		{
+			name:     "Valid",
+			header:   "Bearer " + signTestToken(t, testClaims(nil)),
+			wantCode: codes.OK,
+		},
+		{
+			name:     "No token",
+			header:   "",
+			wantCode: codes.Unauthenticated,
+			wantMsg:  "invalid authorization metadata",
+		},
+		{
+			name:     "Invalid token",
+			header:   "Bearer " + signTestToken(t, testClaims(jwt.MapClaims{"aud": "other"})),
+			wantCode: codes.Unauthenticated,
+			wantMsg:  "invalid token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usernames := make(chan interface{}, 2)
+			client, stop := startTestGRPCServer(t, newTestCognito(t), usernames)
+			defer stop()
+			ctx := context.Background()
+			if tt.header != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.header)
+			}
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			// unary
+			_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantMsg, status.Convert(err).Message())
+			} else {
+				assert.Equal(t, "anaya", <-usernames)
+			}
+
+			// stream
+			stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+			require.NoError(t, err)
+			_, err = stream.Recv()
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantMsg, status.Convert(err).Message())
+			} else {
+				assert.Equal(t, "anaya", <-usernames)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package cognito
+
+// Logger receives diagnostics about JWKS fetches, key refreshes and rejected
+// tokens. Most logging libraries provide these methods, others can be plugged
+// in with a thin adapter.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// nopLogger discards everything, it is used when no Logger is set
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+
+func (c *Cognito) logger() Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return nopLogger{}
+}
@@ -0,0 +1,88 @@
+package cognito
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.record("debug: "+format, args...)
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.record("error: "+format, args...)
+}
+
+func (l *recordingLogger) record(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, fmt.Sprintf(format, args...))
+}
+
+func TestCognito_Logger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	logger := &recordingLogger{}
+	c := newTestCognito(t, WithLogger(logger))
+	c.JWKSURL = ts.URL
+
+	_, err := c.VerifyToken(signTestToken(t, testClaims(nil)))
+	assert.NoError(t, err)
+	_, err = c.VerifyToken(signTestToken(t, testClaims(jwt.MapClaims{"aud": "other"})))
+	assert.Error(t, err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims(nil))
+	token.Header["kid"] = "rotated"
+	tokenStr, _ := token.SignedString(testPrivateKey(t))
+	_, err = c.VerifyToken(tokenStr)
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{
+		"debug: cognito: token rejected: audience is invalid",
+		"debug: cognito: kid rotated is not cached",
+		"error: cognito: fetching JWKS from " + ts.URL + ": unexpected JWKS response status 503 Service Unavailable",
+		"debug: cognito: token rejected: invalid kid rotated",
+	}, logger.entries)
+}
+
+func TestCognito_Logger_SkippedKeys(t *testing.T) {
+	jwks := fmt.Sprintf(`{"keys": [
+		{"alg": "RS256", "e": "AQAB", "kid": %[1]q, "kty": "RSA", "n": %[2]q, "use": "sig"},
+		{"alg": "ES256", "e": "AQAB", "kid": "eckid", "kty": "RSA", "n": %[2]q, "use": "sig"},
+		{"alg": "RS256", "e": "AQAB", "kid": "enckid", "kty": "RSA", "n": %[2]q, "use": "enc"}
+	]}`, testKid, base64.RawURLEncoding.EncodeToString(testPrivateKey(t).PublicKey.N.Bytes()))
+
+	logger := &recordingLogger{}
+	c := &Cognito{}
+	WithLogger(logger)(c)
+	keys, err := c.parseJWKS(strings.NewReader(jwks))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, []string{
+		"debug: cognito: skipping kid eckid with unsupported alg ES256",
+		"debug: cognito: skipping kid enckid with use enc",
+	}, logger.entries)
+}
+
+func TestCognito_Logger_Default(t *testing.T) {
+	c := newTestCognito(t)
+	assert.Equal(t, nopLogger{}, c.logger())
+	_, err := c.VerifyToken("not a token")
+	assert.Error(t, err)
+}
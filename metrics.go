@@ -0,0 +1,40 @@
+package cognito
+
+import "errors"
+
+// Results passed to OnVerify. They are stable, so they can be used as metric labels.
+const (
+	VerifyResultSuccess      = "success"
+	VerifyResultMalformed    = "malformed"
+	VerifyResultUnknownKid   = "unknown_kid"
+	VerifyResultBadSignature = "bad_signature"
+	VerifyResultExpired      = "expired"
+	VerifyResultNotValidYet  = "not_valid_yet"
+	VerifyResultBadIssuer    = "bad_issuer"
+	VerifyResultBadAudience  = "bad_audience"
+	VerifyResultInvalid      = "invalid"
+)
+
+// verifyResult labels the outcome of a verification, errors without a
+// dedicated label are reported as VerifyResultInvalid
+func verifyResult(err error) string {
+	switch {
+	case err == nil:
+		return VerifyResultSuccess
+	case errors.Is(err, ErrMalformedToken):
+		return VerifyResultMalformed
+	case errors.Is(err, ErrInvalidKid):
+		return VerifyResultUnknownKid
+	case errors.Is(err, ErrInvalidSignature):
+		return VerifyResultBadSignature
+	case errors.Is(err, ErrTokenExpired):
+		return VerifyResultExpired
+	case errors.Is(err, ErrTokenNotValidYet), errors.Is(err, ErrTokenUsedBeforeIssued):
+		return VerifyResultNotValidYet
+	case errors.Is(err, ErrInvalidIssuer):
+		return VerifyResultBadIssuer
+	case errors.Is(err, ErrInvalidAudience):
+		return VerifyResultBadAudience
+	}
+	return VerifyResultInvalid
+}
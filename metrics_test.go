@@ -0,0 +1,91 @@
+package cognito
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCognito_OnVerify(t *testing.T) {
+	now := time.Now()
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	badSig := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims(nil))
+	badSig.Header["kid"] = testKid
+	badSigStr, err := badSig.SignedString(other)
+	require.NoError(t, err)
+	unknownKid := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims(nil))
+	unknownKid.Header["kid"] = "unknown"
+	unknownKidStr, err := unknownKid.SignedString(testPrivateKey(t))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		tokenStr   string
+		wantResult string
+	}{
+		{
+			name:       "Success",
+			tokenStr:   signTestToken(t, testClaims(nil)),
+			wantResult: VerifyResultSuccess,
+		},
+		{
+			name:       "Malformed",
+			tokenStr:   "not-a-token",
+			wantResult: VerifyResultMalformed,
+		},
+		{
+			name:       "Unknown kid",
+			tokenStr:   unknownKidStr,
+			wantResult: VerifyResultUnknownKid,
+		},
+		{
+			name:       "Bad signature",
+			tokenStr:   badSigStr,
+			wantResult: VerifyResultBadSignature,
+		},
+		{
+			name:       "Expired",
+			tokenStr:   signTestToken(t, testClaims(jwt.MapClaims{"exp": now.Add(-time.Hour).Unix()})),
+			wantResult: VerifyResultExpired,
+		},
+		{
+			name:       "Not valid yet",
+			tokenStr:   signTestToken(t, testClaims(jwt.MapClaims{"nbf": now.Add(time.Hour).Unix()})),
+			wantResult: VerifyResultNotValidYet,
+		},
+		{
+			name:       "Bad issuer",
+			tokenStr:   signTestToken(t, testClaims(jwt.MapClaims{"iss": "https://example.com"})),
+			wantResult: VerifyResultBadIssuer,
+		},
+		{
+			name:       "Bad audience",
+			tokenStr:   signTestToken(t, testClaims(jwt.MapClaims{"aud": "other"})),
+			wantResult: VerifyResultBadAudience,
+		},
+		{
+			name:       "Other",
+			tokenStr:   signTestToken(t, testClaims(jwt.MapClaims{"token_use": "refresh"})),
+			wantResult: VerifyResultInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []string
+			var gotErr error
+			c := newTestCognito(t, WithOnVerify(func(result string, err error) {
+				results = append(results, result)
+				gotErr = err
+			}))
+			_, err := c.VerifyToken(tt.tokenStr)
+			assert.Equal(t, []string{tt.wantResult}, results)
+			assert.Equal(t, err, gotErr)
+		})
+	}
+}
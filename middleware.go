@@ -1,7 +1,10 @@
 package cognito
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -10,23 +13,292 @@ import (
 )
 
 func (cog *Cognito) Authorize(c *gin.Context) {
-	tokenHeader, err := tokenFromAuthHeader(c.Request)
+	tokenHeader, err := cog.tokenFromRequest(c.Request)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "invalid Authorization header"})
+		cog.abort(c, "invalid Authorization header", err)
 		return
 	}
-	token, err := cog.VerifyToken(tokenHeader)
+	token, err := cog.VerifyTokenWithContext(c.Request.Context(), tokenHeader)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "invalid token"})
+		cog.abort(c, "invalid token", err)
 		return
 	}
-	c.Set("token", token)
-	c.Set("email", token.Claims.(jwt.MapClaims)["email"])
+	keys := cog.contextKeys()
+	claims, _ := token.Claims.(jwt.MapClaims)
+	c.Set(keys.Token, token)
+	c.Set(keys.Email, claims["email"])
+	c.Set(keys.Username, tokenUsername(token))
+	c.Set(keys.Groups, tokenGroups(token))
+	if cog.ClaimsContextPrefix != "" {
+		for k, v := range claims {
+			c.Set(cog.ClaimsContextPrefix+k, v)
+		}
+	}
 	c.Next()
 }
 
-func tokenFromAuthHeader(r *http.Request) (string, error) {
-	authHeader := r.Header.Get("Authorization")
+type contextKey string
+
+const (
+	// TokenContextKey is the request context key under which Middleware stores the verified *jwt.Token
+	TokenContextKey contextKey = "token"
+
+	// GroupsContextKey is the request context key under which Middleware stores the cognito:groups as []string
+	GroupsContextKey contextKey = "groups"
+)
+
+// Middleware is the net/http counterpart of Authorize. It verifies the bearer
+// token and stores it in the request context, see TokenFromContext. Failures
+// get the same 403 JSON response as Authorize.
+func (cog *Cognito) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenStr, err := cog.tokenFromRequest(r)
+		if err != nil {
+			cog.forbid(w, "invalid Authorization header")
+			return
+		}
+		token, err := cog.VerifyTokenWithContext(r.Context(), tokenStr)
+		if err != nil {
+			cog.forbid(w, "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(contextWithToken(r.Context(), token)))
+	})
+}
+
+// ContextKeys names the gin, echo and fiber context keys the middlewares set, empty
+// fields keep the defaults "token", "email", "username" and "groups".
+type ContextKeys struct {
+	Token    string
+	Email    string
+	Username string
+	Groups   string
+}
+
+// contextKeys returns the configured ContextKeys with defaults filled in
+func (cog *Cognito) contextKeys() ContextKeys {
+	keys := cog.ContextKeys
+	if keys.Token == "" {
+		keys.Token = "token"
+	}
+	if keys.Email == "" {
+		keys.Email = "email"
+	}
+	if keys.Username == "" {
+		keys.Username = "username"
+	}
+	if keys.Groups == "" {
+		keys.Groups = "groups"
+	}
+	return keys
+}
+
+// contextWithToken stores token and its groups for TokenFromContext and GroupsFromContext
+func contextWithToken(ctx context.Context, token *jwt.Token) context.Context {
+	ctx = context.WithValue(ctx, TokenContextKey, token)
+	return context.WithValue(ctx, GroupsContextKey, tokenGroups(token))
+}
+
+// TokenFromContext returns the token stored by Middleware or the gRPC interceptors.
+func TokenFromContext(ctx context.Context) (*jwt.Token, bool) {
+	token, ok := ctx.Value(TokenContextKey).(*jwt.Token)
+	return token, ok
+}
+
+// GroupsFromContext returns the cognito:groups stored by Middleware or the gRPC interceptors.
+func GroupsFromContext(ctx context.Context) ([]string, bool) {
+	groups, ok := ctx.Value(GroupsContextKey).([]string)
+	return groups, ok
+}
+
+// RequireExactScopes returns a middleware, to be used after Authorize, that
+// rejects tokens whose scope claim is not exactly the given set. Order and
+// duplicates are ignored, but both missing and extra scopes are rejected.
+func (cog *Cognito) RequireExactScopes(scopes ...string) gin.HandlerFunc {
+	want := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		want[scope] = true
+	}
+	return func(c *gin.Context) {
+		v, _ := c.Get(cog.contextKeys().Token)
+		token, ok := v.(*jwt.Token)
+		if !ok {
+			cog.abort(c, "invalid token", errors.New("no verified token in context"))
+			return
+		}
+		got := make(map[string]bool)
+		for _, scope := range tokenScopes(token) {
+			got[scope] = true
+		}
+		if len(got) != len(want) {
+			cog.abort(c, "invalid scope", errors.New("scopes do not match"))
+			return
+		}
+		for scope := range got {
+			if !want[scope] {
+				cog.abort(c, "invalid scope", errors.New("scopes do not match"))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireScope returns a middleware, to be used after Authorize, that rejects
+// tokens whose scope claim doesn't contain scope, see HasScope.
+func (cog *Cognito) RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, _ := c.Get(cog.contextKeys().Token)
+		token, ok := v.(*jwt.Token)
+		if !ok {
+			cog.abort(c, "invalid token", errors.New("no verified token in context"))
+			return
+		}
+		if !HasScope(token, scope) {
+			cog.abort(c, "invalid scope", fmt.Errorf("token is missing scope %s", scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireGroup returns a middleware, to be used after Authorize, that rejects
+// tokens whose cognito:groups claim doesn't contain group.
+func (cog *Cognito) RequireGroup(group string) gin.HandlerFunc {
+	return cog.RequireAnyGroup(group)
+}
+
+// RequireAnyGroup returns a middleware, to be used after Authorize, that
+// rejects tokens whose cognito:groups claim contains none of groups.
+func (cog *Cognito) RequireAnyGroup(groups ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, _ := c.Get(cog.contextKeys().Token)
+		token, ok := v.(*jwt.Token)
+		if !ok {
+			cog.abort(c, "invalid token", errors.New("no verified token in context"))
+			return
+		}
+		for _, group := range tokenGroups(token) {
+			if containsString(groups, group) {
+				c.Next()
+				return
+			}
+		}
+		cog.abort(c, "invalid group", errors.New("token is not in any required group"))
+	}
+}
+
+// tokenUsername returns the claim named by usernameClaim
+func tokenUsername(token *jwt.Token) interface{} {
+	claims, _ := token.Claims.(jwt.MapClaims)
+	return claims[usernameClaim(claims)]
+}
+
+// tokenGroups returns the cognito:groups claim, empty when the token has no groups
+func tokenGroups(token *jwt.Token) []string {
+	claims, _ := token.Claims.(jwt.MapClaims)
+	groups := []string{}
+	switch v := claims["cognito:groups"].(type) {
+	case []interface{}:
+		// decoded from JSON
+		for _, g := range v {
+			if group, ok := g.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	case []string:
+		groups = append(groups, v...)
+	}
+	return groups
+}
+
+// HasScope reports whether the space delimited scope claim of an access token
+// contains scope. Tokens without a scope claim, such as id tokens, have no scopes.
+func HasScope(token *jwt.Token, scope string) bool {
+	return containsString(tokenScopes(token), scope)
+}
+
+// tokenScopes splits the space delimited scope claim
+func tokenScopes(token *jwt.Token) []string {
+	claims, _ := token.Claims.(jwt.MapClaims)
+	scope, _ := claims["scope"].(string)
+	return strings.Fields(scope)
+}
+
+// VerifyRequest reads the token from r with the configured TokenExtractor and verifies it.
+func (cog *Cognito) VerifyRequest(r *http.Request) (*jwt.Token, error) {
+	tokenStr, err := cog.tokenFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return cog.VerifyTokenWithContext(r.Context(), tokenStr)
+}
+
+// abort rejects the request. err is passed to OnError when it is set, otherwise
+// message is sent with the configured CORS headers so browsers on other
+// origins can read the error.
+func (cog *Cognito) abort(c *gin.Context, message string, err error) {
+	if cog.OnError != nil {
+		cog.OnError(c, err)
+		c.Abort()
+		return
+	}
+	for k, v := range cog.ErrorCORSHeaders {
+		c.Header(k, v)
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": message})
+}
+
+// forbid writes the same response as abort for net/http handlers
+func (cog *Cognito) forbid(w http.ResponseWriter, message string) {
+	for k, v := range cog.ErrorCORSHeaders {
+		w.Header().Set(k, v)
+	}
+	body, _ := json.Marshal(map[string]string{"message": message})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write(body)
+}
+
+// tokenFromRequest reads the token with the configured TokenExtractor, FromAuthHeader by default
+func (cog *Cognito) tokenFromRequest(r *http.Request) (string, error) {
+	if cog.TokenExtractor != nil {
+		return cog.TokenExtractor(r)
+	}
+	return FromAuthHeader(r)
+}
+
+// FromAuthHeader reads a bearer token from the Authorization header. It is the
+// default TokenExtractor.
+func FromAuthHeader(r *http.Request) (string, error) {
+	return bearerToken(r.Header.Get("Authorization"))
+}
+
+// FromCookie returns a TokenExtractor that reads the token from the named cookie.
+func FromCookie(name string) func(*http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return "", errors.New("no token")
+		}
+		return cookie.Value, nil
+	}
+}
+
+// FromHeader returns a TokenExtractor that reads the raw token, without a
+// Bearer prefix, from the named header.
+func FromHeader(name string) func(*http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		token := strings.TrimSpace(r.Header.Get(name))
+		if token == "" {
+			return "", errors.New("no token")
+		}
+		return token, nil
+	}
+}
+
+// bearerToken extracts the token from an Authorization header value
+func bearerToken(authHeader string) (string, error) {
 	if authHeader == "" {
 		return "", errors.New("no token")
 	}
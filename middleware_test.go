@@ -1,6 +1,7 @@
 package cognito
 
 import (
+	"context"
 	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
@@ -176,7 +177,496 @@ mQIDAQAB
 	}
 }
 
-func Test_tokenFromAuthHeader(t *testing.T) {
+func TestCognito_Authorize_ErrorCORSHeaders(t *testing.T) {
+	cog := &Cognito{
+		ErrorCORSHeaders: map[string]string{
+			"Access-Control-Allow-Origin":      "https://app.example.com",
+			"Access-Control-Allow-Credentials": "true",
+		},
+	}
+	r := gin.New()
+	r.GET("/user", cog.Authorize, func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCognito_Authorize_ClaimsContextPrefix(t *testing.T) {
+	cog := newTestCognito(t, WithClaimsContextPrefix("claim:"))
+	r := gin.New()
+	r.GET("/user", cog.Authorize, func(c *gin.Context) {
+		assert.Equal(t, "anaya@example.com", c.GetString("claim:email"))
+		assert.Equal(t, "anaya", c.GetString("claim:cognito:username"))
+		c.String(http.StatusOK, "ok")
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, testClaims(nil)))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCognito_Authorize_ContextKeysAndOnError(t *testing.T) {
+	var gotErr error
+	cog := newTestCognito(t,
+		WithContextKeys(ContextKeys{Token: "cognito:token"}),
+		WithOnError(func(c *gin.Context, err error) {
+			gotErr = err
+			c.JSON(http.StatusUnauthorized, gin.H{"error": gin.H{"code": "unauthenticated"}})
+		}),
+	)
+	r := gin.New()
+	r.GET("/user", cog.Authorize, cog.RequireGroup("admin"), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/me", cog.Authorize, func(c *gin.Context) {
+		_, exists := c.Get("token")
+		assert.False(t, exists)
+		token, _ := c.Get("cognito:token")
+		assert.IsType(t, &jwt.Token{}, token)
+		c.String(http.StatusOK, c.GetString("username"))
+	})
+
+	// custom token key
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, testClaims(nil)))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "anaya", w.Body.String())
+
+	// invalid token
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, testClaims(jwt.MapClaims{"aud": "other"})))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `{"error":{"code":"unauthenticated"}}`, w.Body.String())
+	assert.True(t, errors.Is(gotErr, ErrInvalidAudience), "got %v", gotErr)
+
+	// RequireGroup reads the custom token key and reports through OnError
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, testClaims(nil)))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.EqualError(t, gotErr, "token is not in any required group")
+}
+
+func TestCognito_Authorize_Username(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{
+			name:   "Id token",
+			claims: testClaims(nil),
+		},
+		{
+			name:   "Access token",
+			claims: testClaims(jwt.MapClaims{"token_use": "access", "aud": nil, "client_id": testClientId, "cognito:username": nil, "username": "anaya"}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cog := newTestCognito(t)
+			r := gin.New()
+			r.GET("/user", cog.Authorize, func(c *gin.Context) {
+				username, _ := c.Get("username")
+				assert.Equal(t, "anaya", username)
+				c.String(http.StatusOK, "ok")
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+			req.Header.Set("Authorization", "Bearer "+signTestToken(t, tt.claims))
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
+
+func TestCognito_Authorize_Groups(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups interface{}
+		want   []string
+	}{
+		{
+			name:   "Two groups",
+			groups: []string{"admin", "editors"},
+			want:   []string{"admin", "editors"},
+		},
+		{
+			name:   "Empty groups",
+			groups: []string{},
+			want:   []string{},
+		},
+		{
+			name:   "Missing groups",
+			groups: nil,
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cog := newTestCognito(t)
+			header := "Bearer " + signTestToken(t, testClaims(jwt.MapClaims{"cognito:groups": tt.groups}))
+
+			r := gin.New()
+			r.GET("/user", cog.Authorize, func(c *gin.Context) {
+				groups, _ := c.Get("groups")
+				assert.Equal(t, tt.want, groups)
+				c.String(http.StatusOK, "ok")
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+			req.Header.Set("Authorization", header)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			h := cog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				groups, ok := GroupsFromContext(r.Context())
+				assert.True(t, ok)
+				assert.Equal(t, tt.want, groups)
+			}))
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
+
+func TestCognito_RequireExactScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    interface{}
+		wantCode int
+	}{
+		{
+			name:     "Exact match",
+			scope:    "myapi/write myapi/read",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Exact match with duplicates",
+			scope:    "myapi/read myapi/write myapi/read",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Subset",
+			scope:    "myapi/read",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Superset",
+			scope:    "myapi/read myapi/write myapi/admin",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Missing scope",
+			scope:    nil,
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cog := newTestCognito(t)
+			r := gin.New()
+			r.GET("/user", cog.Authorize, cog.RequireExactScopes("myapi/read", "myapi/write"), func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+			req.Header.Set("Authorization", "Bearer "+signTestToken(t, testClaims(jwt.MapClaims{"scope": tt.scope})))
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestCognito_RequireScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    interface{}
+		wantCode int
+	}{
+		{
+			name:     "Multiple scopes",
+			scope:    "aws.cognito.signin.user.admin myapi/read",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Single scope",
+			scope:    "myapi/read",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Other scopes",
+			scope:    "aws.cognito.signin.user.admin myapi/write",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Prefix only",
+			scope:    "myapi/readonly",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Missing scope",
+			scope:    nil,
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cog := newTestCognito(t)
+			claims := testClaims(jwt.MapClaims{"scope": tt.scope})
+			assert.Equal(t, tt.wantCode == http.StatusOK, HasScope(&jwt.Token{Claims: claims}, "myapi/read"))
+
+			r := gin.New()
+			r.GET("/user", cog.Authorize, cog.RequireScope("myapi/read"), func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+			req.Header.Set("Authorization", "Bearer "+signTestToken(t, claims))
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode != http.StatusOK {
+				assert.Equal(t, `{"message":"invalid scope"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCognito_Middleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			header:   "Bearer " + signTestToken(t, testClaims(nil)),
+			wantCode: http.StatusOK,
+			wantBody: "anaya",
+		},
+		{
+			name:     "No token",
+			header:   "",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"message":"invalid Authorization header"}`,
+		},
+		{
+			name:     "Invalid token",
+			header:   "Bearer " + signTestToken(t, testClaims(jwt.MapClaims{"aud": "other"})),
+			wantCode: http.StatusForbidden,
+			wantBody: `{"message":"invalid token"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cog := newTestCognito(t, WithErrorCORSHeaders(map[string]string{"Access-Control-Allow-Origin": "https://app.example.com"}))
+			h := cog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				token, ok := TokenFromContext(r.Context())
+				assert.True(t, ok)
+				w.Write([]byte(token.Claims.(jwt.MapClaims)["cognito:username"].(string)))
+			}))
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			h.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+
+			// failures match the gin middleware
+			if tt.wantCode != http.StatusOK {
+				r := gin.New()
+				r.GET("/user", cog.Authorize)
+				ginW := httptest.NewRecorder()
+				r.ServeHTTP(ginW, req)
+				assert.Equal(t, ginW.Code, w.Code)
+				assert.Equal(t, ginW.Body.String(), w.Body.String())
+				assert.Equal(t, ginW.Header(), w.Header())
+			}
+		})
+	}
+
+	_, ok := TokenFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestCognito_TokenExtractor(t *testing.T) {
+	tokenStr := signTestToken(t, testClaims(nil))
+	tests := []struct {
+		name      string
+		extractor func(*http.Request) (string, error)
+		setup     func(r *http.Request)
+		wantCode  int
+	}{
+		{
+			name:      "Cookie",
+			extractor: FromCookie("id_token"),
+			setup: func(r *http.Request) {
+				r.AddCookie(&http.Cookie{Name: "id_token", Value: tokenStr})
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "Missing cookie",
+			extractor: FromCookie("id_token"),
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+tokenStr)
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:      "Custom header",
+			extractor: FromHeader("X-Auth-Token"),
+			setup: func(r *http.Request) {
+				r.Header.Set("X-Auth-Token", tokenStr)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "Missing custom header",
+			extractor: FromHeader("X-Auth-Token"),
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+tokenStr)
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:      "Default",
+			extractor: nil,
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+tokenStr)
+			},
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cog := newTestCognito(t, WithTokenExtractor(tt.extractor))
+			req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+			tt.setup(req)
+
+			w := httptest.NewRecorder()
+			cog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			r := gin.New()
+			r.GET("/user", cog.Authorize)
+			ginW := httptest.NewRecorder()
+			r.ServeHTTP(ginW, req)
+			assert.Equal(t, tt.wantCode, ginW.Code)
+		})
+	}
+}
+
+func TestCognito_RequireGroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		require  func(cog *Cognito) gin.HandlerFunc
+		groups   interface{}
+		wantCode int
+	}{
+		{
+			name:     "In group",
+			require:  func(cog *Cognito) gin.HandlerFunc { return cog.RequireGroup("admin") },
+			groups:   []string{"editor", "admin"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Not in group",
+			require:  func(cog *Cognito) gin.HandlerFunc { return cog.RequireGroup("admin") },
+			groups:   []string{"editor"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Missing groups",
+			require:  func(cog *Cognito) gin.HandlerFunc { return cog.RequireGroup("admin") },
+			groups:   nil,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "In any group",
+			require:  func(cog *Cognito) gin.HandlerFunc { return cog.RequireAnyGroup("admin", "editor") },
+			groups:   []string{"editor"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "In none of the groups",
+			require:  func(cog *Cognito) gin.HandlerFunc { return cog.RequireAnyGroup("admin", "editor") },
+			groups:   []string{"viewer"},
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cog := newTestCognito(t)
+			r := gin.New()
+			r.GET("/user", cog.Authorize, tt.require(cog), func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+			req.Header.Set("Authorization", "Bearer "+signTestToken(t, testClaims(jwt.MapClaims{"cognito:groups": tt.groups})))
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestCognito_VerifyRequest(t *testing.T) {
+	cog := newTestCognito(t)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantErr error
+	}{
+		{
+			name:    "Authorization header",
+			headers: map[string]string{"Authorization": "Bearer " + signTestToken(t, testClaims(nil))},
+			wantErr: nil,
+		},
+		{
+			name:    "No token",
+			headers: map[string]string{},
+			wantErr: errors.New("no token"),
+		},
+		{
+			name:    "Invalid token",
+			headers: map[string]string{"Authorization": "Bearer " + signTestToken(t, testClaims(jwt.MapClaims{"aud": "other"}))},
+			wantErr: ErrInvalidAudience,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/user", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			token, err := cog.VerifyRequest(req)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "anaya", token.Claims.(jwt.MapClaims)["cognito:username"])
+			}
+		})
+	}
+}
+
+func TestFromAuthHeader(t *testing.T) {
 	type args struct {
 		r *http.Request
 	}
@@ -247,7 +737,7 @@ func Test_tokenFromAuthHeader(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tokenFromAuthHeader(tt.args.r)
+			got, err := FromAuthHeader(tt.args.r)
 			assert.Equal(t, tt.wantErr, err)
 			assert.Equal(t, tt.want, got)
 		})
@@ -0,0 +1,45 @@
+package cognito
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// MultiCognito verifies tokens from several user pools, picking the pool by
+// the token's iss claim. Each pool keeps its own keys and settings.
+// The zero value is ready to use.
+type MultiCognito struct {
+	mu    sync.RWMutex
+	pools map[string]*Cognito
+}
+
+// Add registers pool under its Iss, replacing any pool with the same issuer.
+func (m *MultiCognito) Add(pool *Cognito) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pools == nil {
+		m.pools = make(map[string]*Cognito)
+	}
+	m.pools[pool.Iss] = pool
+}
+
+// VerifyToken reads the unverified iss claim of tokenStr and verifies the
+// token with the matching pool. Tokens from other issuers fail with
+// ErrUnknownIssuer.
+func (m *MultiCognito) VerifyToken(tokenStr string) (*jwt.Token, error) {
+	_, claims, err := ParseUnverified(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	iss, _ := claims["iss"].(string)
+
+	m.mu.RLock()
+	pool, ok := m.pools[iss]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownIssuer, iss)
+	}
+	return pool.VerifyToken(tokenStr)
+}
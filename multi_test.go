@@ -0,0 +1,63 @@
+package cognito
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiCognito_VerifyToken(t *testing.T) {
+	otherIss := "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_other"
+	m := &MultiCognito{}
+	m.Add(newTestCognito(t))
+	m.Add(newTestCognito(t, WithIssuer(otherIss), WithClientID("otherclientexample")))
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr error
+	}{
+		{
+			name:    "First pool",
+			claims:  testClaims(nil),
+			wantErr: nil,
+		},
+		{
+			name:    "Second pool",
+			claims:  testClaims(jwt.MapClaims{"iss": otherIss, "aud": "otherclientexample"}),
+			wantErr: nil,
+		},
+		{
+			name:    "Audience of another pool",
+			claims:  testClaims(jwt.MapClaims{"iss": otherIss}),
+			wantErr: ErrInvalidAudience,
+		},
+		{
+			name:    "Unknown issuer",
+			claims:  testClaims(jwt.MapClaims{"iss": "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_unknown"}),
+			wantErr: ErrUnknownIssuer,
+		},
+		{
+			name:    "Missing issuer",
+			claims:  testClaims(jwt.MapClaims{"iss": nil}),
+			wantErr: ErrUnknownIssuer,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := m.VerifyToken(signTestToken(t, tt.claims))
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.claims["iss"], token.Claims.(jwt.MapClaims)["iss"])
+		})
+	}
+
+	_, err := (&MultiCognito{}).VerifyToken("not-a-token")
+	assert.Error(t, err)
+}
@@ -0,0 +1,342 @@
+package cognito
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Option configures optional behaviour of a Cognito client.
+type Option func(*Cognito)
+
+// WithClientID sets the app client id tokens must be issued for.
+func WithClientID(clientId string) Option {
+	return func(c *Cognito) {
+		c.ClientId = clientId
+	}
+}
+
+// WithClientIDs accepts tokens issued to any of the given app clients, in
+// addition to the client id passed to NewCognitoClient.
+func WithClientIDs(clientIds ...string) Option {
+	return func(c *Cognito) {
+		c.ClientIds = append(c.ClientIds, clientIds...)
+	}
+}
+
+// WithIssuer sets the issuer tokens must come from, e.g.
+// https://cognito-idp.<region>.amazonaws.com/<user pool id>.
+func WithIssuer(iss string) Option {
+	return func(c *Cognito) {
+		c.Iss = iss
+	}
+}
+
+// WithMaxTokenLifetime rejects tokens whose total lifetime (exp - iat) exceeds d.
+func WithMaxTokenLifetime(d time.Duration) Option {
+	return func(c *Cognito) {
+		c.MaxTokenLifetime = d
+	}
+}
+
+// WithValidateSubUUID rejects tokens whose sub claim is missing or not a UUID.
+func WithValidateSubUUID() Option {
+	return func(c *Cognito) {
+		c.ValidateSubUUID = true
+	}
+}
+
+// WithErrorCORSHeaders sets headers that are added to auth failure responses,
+// so a browser client on another origin can read the error body.
+func WithErrorCORSHeaders(headers map[string]string) Option {
+	return func(c *Cognito) {
+		c.ErrorCORSHeaders = headers
+	}
+}
+
+// WithTimeFunc sets the clock used for time based checks.
+func WithTimeFunc(f func() time.Time) Option {
+	return func(c *Cognito) {
+		c.TimeFunc = f
+	}
+}
+
+// WithLeeway tolerates clock skew of up to d when checking exp, nbf and iat.
+func WithLeeway(d time.Duration) Option {
+	return func(c *Cognito) {
+		c.Leeway = d
+	}
+}
+
+// WithAllowedKIDs restricts verification to the given kids, even if the JWKS
+// contains other keys.
+func WithAllowedKIDs(kids ...string) Option {
+	return func(c *Cognito) {
+		c.AllowedKIDs = kids
+	}
+}
+
+// WithOriginJTIRevocationChecker rejects tokens whose origin_jti is reported
+// as revoked by checker. Tokens without an origin_jti claim are not checked.
+func WithOriginJTIRevocationChecker(checker RevocationChecker) Option {
+	return func(c *Cognito) {
+		c.OriginJTIRevocationChecker = checker
+	}
+}
+
+// WithRequiredClaims rejects tokens missing any of the named claims with ErrMissingClaim.
+func WithRequiredClaims(names ...string) Option {
+	return func(c *Cognito) {
+		c.RequiredClaims = names
+	}
+}
+
+// WithRejectEmptyClaims treats required claims holding an empty string as missing.
+func WithRejectEmptyClaims() Option {
+	return func(c *Cognito) {
+		c.RejectEmptyClaims = true
+	}
+}
+
+// WithRequestSigner sets a function that is called on the JWKS request before
+// it is sent, so requests to private JWKS mirrors can be signed (e.g. SigV4)
+// without a custom transport.
+func WithRequestSigner(signer func(*http.Request) error) Option {
+	return func(c *Cognito) {
+		c.RequestSigner = signer
+	}
+}
+
+// WithMinRSABits skips RSA keys smaller than bits when loading the JWKS, so
+// tokens signed with them fail with an invalid kid. Each skipped key is
+// reported to the Logger.
+func WithMinRSABits(bits int) Option {
+	return func(c *Cognito) {
+		c.MinRSABits = bits
+	}
+}
+
+// WithClaimsContextPrefix makes Authorize set each verified claim in the gin
+// context under prefix + claim name, e.g. "claim:email".
+func WithClaimsContextPrefix(prefix string) Option {
+	return func(c *Cognito) {
+		c.ClaimsContextPrefix = prefix
+	}
+}
+
+// WithFallbackExpiry accepts tokens without an exp claim until iat + maxAge.
+// By default tokens without exp are rejected.
+func WithFallbackExpiry(maxAge time.Duration) Option {
+	return func(c *Cognito) {
+		c.FallbackExpiry = maxAge
+	}
+}
+
+// WithCollectAllErrors makes VerifyToken run every claim check and return all
+// failures combined, instead of stopping at the first one.
+func WithCollectAllErrors() Option {
+	return func(c *Cognito) {
+		c.CollectAllErrors = true
+	}
+}
+
+// WithTracer wraps token verification and JWKS fetches in spans created by tracer.
+func WithTracer(tracer Tracer) Option {
+	return func(c *Cognito) {
+		c.Tracer = tracer
+	}
+}
+
+// WithAudClientIDConsistency rejects tokens carrying both aud and client_id
+// when they disagree. Tokens with only one of the claims are not affected.
+func WithAudClientIDConsistency() Option {
+	return func(c *Cognito) {
+		c.AudClientIDConsistency = true
+	}
+}
+
+// WithIssuerSuffix additionally accepts issuers on other hosts, such as a
+// Cognito custom domain, when the issuer is an https URL whose path is exactly
+// suffix (the user pool path, e.g. "/us-east-1_example").
+func WithIssuerSuffix(suffix string) Option {
+	return func(c *Cognito) {
+		c.IssuerSuffix = suffix
+	}
+}
+
+// WithLenientBase64 accepts tokens whose signature segment uses the standard
+// base64 alphabet or padding, which violates the JWT spec but is produced by
+// some non-conformant clients. By default such tokens are rejected.
+func WithLenientBase64() Option {
+	return func(c *Cognito) {
+		c.LenientBase64 = true
+	}
+}
+
+// WithRequireTokenUse rejects tokens without a token_use claim with
+// ErrMissingTokenUse, whatever its value.
+func WithRequireTokenUse() Option {
+	return func(c *Cognito) {
+		c.RequireTokenUse = true
+	}
+}
+
+// WithRejectDuplicateClaims rejects tokens whose payload contains the same
+// key twice with ErrDuplicateClaim, instead of silently using the last value.
+func WithRejectDuplicateClaims() Option {
+	return func(c *Cognito) {
+		c.RejectDuplicateClaims = true
+	}
+}
+
+// WithUserAgent overrides the User-Agent header sent when fetching the JWKS.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Cognito) {
+		c.UserAgent = userAgent
+	}
+}
+
+// WithAllowedTokenUse restricts the accepted token_use values, e.g. "access"
+// for an API that must not accept id tokens.
+func WithAllowedTokenUse(uses ...string) Option {
+	return func(c *Cognito) {
+		c.AllowedTokenUse = uses
+	}
+}
+
+// WithOnKeyRefreshError sets a function called with the error of every failed
+// background refresh started by StartKeyRefresh.
+func WithOnKeyRefreshError(fn func(error)) Option {
+	return func(c *Cognito) {
+		c.OnKeyRefreshError = fn
+	}
+}
+
+// WithHTTPClient sets the client used to fetch the JWKS, e.g. to go through a
+// proxy or use mutual TLS. Without it a client with a 10 second timeout is used.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Cognito) {
+		c.HTTPClient = client
+	}
+}
+
+// WithStrictOIDC requires the claims OIDC Core mandates in an id token: iss and
+// sub as non-empty strings, aud as a string or an array of strings, and exp and
+// iat as numbers. Cognito access tokens have no aud and are rejected.
+func WithStrictOIDC() Option {
+	return func(c *Cognito) {
+		c.StrictOIDC = true
+	}
+}
+
+// WithIssuerAudiences binds each issuer to the audiences it may issue tokens
+// for. Tokens from an unlisted issuer, or with an audience not bound to their
+// issuer, are rejected with ErrIssuerAudienceBinding.
+func WithIssuerAudiences(bindings map[string][]string) Option {
+	return func(c *Cognito) {
+		c.IssuerAudiences = bindings
+	}
+}
+
+// WithPinnedJWKS verifies tokens against a captured JWKS document instead of
+// fetching the keys, e.g. to replay historical tokens against the keys that
+// were live when they were issued. The keys are never refreshed.
+func WithPinnedJWKS(jwks []byte) Option {
+	return func(c *Cognito) {
+		c.PinnedJWKS = jwks
+	}
+}
+
+// WithRetry retries fetching the JWKS up to attempts times in total when it
+// fails with a connection error or a 5xx response, waiting baseDelay before
+// the first retry and doubling the delay after each one. 4xx responses fail
+// immediately.
+func WithRetry(attempts int, baseDelay time.Duration) Option {
+	return func(c *Cognito) {
+		c.RetryAttempts = attempts
+		c.RetryBaseDelay = baseDelay
+	}
+}
+
+// WithTokenExtractor sets where the middlewares read the token from, see
+// FromAuthHeader, FromCookie and FromHeader.
+func WithTokenExtractor(extractor func(*http.Request) (string, error)) Option {
+	return func(c *Cognito) {
+		c.TokenExtractor = extractor
+	}
+}
+
+// WithContextKeys renames the context keys set by Authorize, EchoMiddleware and
+// FiberMiddleware.
+func WithContextKeys(keys ContextKeys) Option {
+	return func(c *Cognito) {
+		c.ContextKeys = keys
+	}
+}
+
+// WithOnError sets a handler that renders gin auth failures in place of the
+// default 403 JSON response.
+func WithOnError(fn func(c *gin.Context, err error)) Option {
+	return func(c *Cognito) {
+		c.OnError = fn
+	}
+}
+
+// WithLogger logs JWKS fetches, key refreshes, kid cache misses and the reason
+// tokens are rejected to logger.
+func WithLogger(logger Logger) Option {
+	return func(c *Cognito) {
+		c.Logger = logger
+	}
+}
+
+// WithMaxKeyStaleness makes Ready fail when the keys haven't been fetched
+// successfully within d.
+func WithMaxKeyStaleness(d time.Duration) Option {
+	return func(c *Cognito) {
+		c.MaxKeyStaleness = d
+	}
+}
+
+// WithOnVerify calls fn after every verification with a stable result label,
+// one of the VerifyResult constants, and the verification error.
+func WithOnVerify(fn func(result string, err error)) Option {
+	return func(c *Cognito) {
+		c.OnVerify = fn
+	}
+}
+
+// WithAllowedAlgs sets the accepted signing algorithms, e.g. "RS256" and
+// "PS256". JWKS keys with an alg must match the token's algorithm, keys
+// without one can be used with any accepted algorithm.
+func WithAllowedAlgs(algs ...string) Option {
+	return func(c *Cognito) {
+		c.AllowedAlgs = algs
+	}
+}
+
+// WithEndpointSuffix sets the DNS suffix NewCognitoClient builds the issuer and
+// JWKS URL with, for partitions that aren't detected from the region.
+func WithEndpointSuffix(suffix string) Option {
+	return func(c *Cognito) {
+		c.EndpointSuffix = suffix
+	}
+}
+
+// WithJWKSURL fetches the keys from url instead of <iss>/.well-known/jwks.json,
+// e.g. from a caching proxy or LocalStack. Tokens are still validated
+// against the issuer.
+func WithJWKSURL(url string) Option {
+	return func(c *Cognito) {
+		c.JWKSURL = url
+	}
+}
+
+// WithStrict rejects tokens missing token_use, iss, exp, iat or aud, which is
+// client_id for access tokens, failing closed instead of skipping their checks.
+func WithStrict() Option {
+	return func(c *Cognito) {
+		c.Strict = true
+	}
+}
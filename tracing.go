@@ -0,0 +1,71 @@
+package cognito
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Tracer starts spans around token verification and JWKS fetches.
+// It covers the small part of a tracing API this package needs, so an
+// OpenTelemetry (or any other) tracer can be plugged in with a thin adapter
+// without the package depending on it.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End()
+}
+
+const (
+	spanVerifyToken = "cognito.VerifyToken"
+	spanFetchJWKS   = "cognito.FetchJWKS"
+)
+
+func (c *Cognito) traceVerifyToken(ctx context.Context, tokenStr string, keys PublicKeys) (*jwt.Token, PublicKey, error) {
+	ctx, span := c.Tracer.Start(ctx, spanVerifyToken)
+	defer span.End()
+
+	// read the header without verification so failed tokens are traced too
+	if unverified, claims, err := ParseUnverified(tokenStr); err == nil {
+		if kid, err := headerKid(unverified); err == nil {
+			_, hit := keys[kid]
+			if !hit {
+				_, hit = c.publicKey(kid)
+			}
+			span.SetAttribute("kid", kid)
+			span.SetAttribute("cache_hit", hit)
+		}
+		if tokenUse, ok := claims["token_use"].(string); ok {
+			span.SetAttribute("token_use", tokenUse)
+		}
+	}
+
+	token, key, err := c.verifyToken(ctx, tokenStr, keys)
+	setSpanResult(span, err)
+	return token, key, err
+}
+
+func (c *Cognito) traceGetPublicKeys(ctx context.Context, url string) (PublicKeys, time.Time, error) {
+	_, span := c.Tracer.Start(ctx, spanFetchJWKS)
+	defer span.End()
+
+	span.SetAttribute("url", url)
+	keys, expiry, err := c.fetchPublicKeys(ctx, url)
+	span.SetAttribute("key_count", len(keys))
+	setSpanResult(span, err)
+	return keys, expiry, err
+}
+
+func setSpanResult(span Span, err error) {
+	if err != nil {
+		span.SetAttribute("result", "error")
+		span.SetAttribute("error", err.Error())
+		return
+	}
+	span.SetAttribute("result", "success")
+}
@@ -0,0 +1,89 @@
+package cognito
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedSpan struct {
+	name       string
+	attributes map[string]interface{}
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	span := &recordedSpan{name: name, attributes: map[string]interface{}{}}
+	r.spans = append(r.spans, span)
+	return ctx, span
+}
+
+func TestCognito_VerifyToken_Tracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	c := newTestCognito(t, WithTracer(tracer))
+
+	_, err := c.VerifyToken(signTestToken(t, testClaims(nil)))
+	require.NoError(t, err)
+	_, err = c.VerifyToken(signTestToken(t, testClaims(jwt.MapClaims{"aud": "other"})))
+	require.Error(t, err)
+
+	require.Len(t, tracer.spans, 2)
+	assert.Equal(t, &recordedSpan{
+		name: spanVerifyToken,
+		attributes: map[string]interface{}{
+			"kid":       testKid,
+			"cache_hit": true,
+			"token_use": "id",
+			"result":    "success",
+		},
+		ended: true,
+	}, tracer.spans[0])
+	assert.Equal(t, "error", tracer.spans[1].attributes["result"])
+	assert.Equal(t, "audience is invalid", tracer.spans[1].attributes["error"])
+	assert.True(t, tracer.spans[1].ended)
+}
+
+func TestCognito_getPublicKeys_Tracer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"keys": []}`))
+	}))
+	defer ts.Close()
+
+	tracer := &recordingTracer{}
+	c := &Cognito{}
+	WithTracer(tracer)(c)
+	_, _, err := c.getPublicKeys(context.Background(), ts.URL)
+	require.NoError(t, err)
+
+	require.Len(t, tracer.spans, 1)
+	assert.Equal(t, &recordedSpan{
+		name: spanFetchJWKS,
+		attributes: map[string]interface{}{
+			"url":       ts.URL,
+			"key_count": 0,
+			"result":    "success",
+		},
+		ended: true,
+	}, tracer.spans[0])
+}